@@ -0,0 +1,81 @@
+package artwork
+
+/*
+AliasTable is an alias table, built from a WeightMap with the Walker/Vose alias method, for sampling items in constant time, however many there are.
+
+The table has a column for each item. Each column holds the chance, Prob, of choosing its own item, and an alias to choose otherwise. Sampling picks a column, then chooses between its item and its alias.
+*/
+type AliasTable[T Weighable] struct {
+	Items []T
+	Prob  []float64
+	Alias []int
+}
+
+// Alias builds an *AliasTable from the weight map. Weights are normalized first, with a warning if they do not sum to 1.0. Items are taken in the stable order given by Items, and those with no weight are left out.
+func (m WeightMap[T]) Alias() *AliasTable[T] {
+	return newAliasTable(m.Normalize())
+}
+
+// newAliasTable builds an *AliasTable from a normalized weight map, nm.
+func newAliasTable[T Weighable](nm WeightMap[T]) *AliasTable[T] {
+	t := &AliasTable[T]{
+		Items: nm.Items(),
+	}
+	n := len(t.Items)
+	t.Prob, t.Alias = make([]float64, n), make([]int, n)
+	if n == 0 {
+		return t
+	}
+	// Scale weights so that the average column holds exactly 1.0, and sort columns by whether they are under or over full.
+	scaled := make([]float64, n)
+	small, large := make([]int, 0, n), make([]int, 0, n)
+	for i, item := range t.Items {
+		scaled[i] = nm[item] * float64(n)
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	// Fill each under-full column from an over-full one, which becomes its alias.
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		t.Prob[s], t.Alias[s] = scaled[s], l
+		scaled[l] -= 1 - scaled[s]
+		if scaled[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// Whatever remains is full, give or take floating point error.
+	for _, i := range append(small, large...) {
+		t.Prob[i], t.Alias[i] = 1, i
+	}
+	return t
+}
+
+// Len returns the number of items in the table.
+func (t *AliasTable[T]) Len() int {
+	return len(t.Items)
+}
+
+// Pick returns the item chosen by f, a number in [0.0, 1.0). The whole part of f scaled by the number of columns picks the column, and the fractional part chooses between the column's item and its alias. Returns the zero value of T if the table is empty.
+func (t *AliasTable[T]) Pick(f float64) T {
+	n := len(t.Items)
+	if n == 0 {
+		var zero T
+		return zero
+	}
+	x := f * float64(n)
+	i := int(x)
+	if i >= n {
+		i = n - 1
+	} else if i < 0 {
+		i = 0
+	}
+	if x-float64(i) < t.Prob[i] {
+		return t.Items[i]
+	}
+	return t.Items[t.Alias[i]]
+}
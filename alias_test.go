@@ -0,0 +1,79 @@
+package artwork
+
+import (
+	"math"
+	"testing"
+)
+
+func testWeights() AttributeWeightMap {
+	return AttributeWeightMap{
+		{TraitType: "Hat", Value: "Red"}:    0.2,
+		{TraitType: "Hat", Value: "Blue"}:   0.15,
+		{TraitType: "Hat", Value: "Green"}:  0.25,
+		{TraitType: "Hat", Value: "Yellow"}: 0.1,
+		{TraitType: "Hat", Value: "None"}:   0.3,
+	}
+}
+
+func TestAliasDistribution(t *testing.T) {
+	m := testWeights()
+	table := m.Alias()
+	// Sweep f evenly across [0, 1); each attribute should be chosen in proportion to its weight.
+	const steps = 100000
+	counts := make(map[Attribute]int)
+	for i := 0; i < steps; i++ {
+		counts[table.Pick(float64(i)/steps)]++
+	}
+	for attr, w := range m {
+		got := float64(counts[attr]) / steps
+		if math.Abs(got-w) > 0.001 {
+			t.Errorf("%s: expected frequency %v, got %v.", attr, w, got)
+		}
+	}
+	// The alias table and intervals must be interchangeable.
+	var samplers = []AttributeSampler{m.Intervals(), table}
+	for _, s := range samplers {
+		if s.Pick(0.999999) == (Attribute{}) {
+			t.Errorf("%T picked nothing.", s)
+		}
+	}
+}
+
+func TestAliasTable(t *testing.T) {
+	m := testWeights()
+	m[Attribute{TraitType: "Hat", Value: "Never"}] = 0
+	table := m.Alias()
+	if table.Len() != 5 {
+		t.Fatalf("Expected 5 columns, leaving out the unweighted attribute, got %d.", table.Len())
+	}
+	// Each attribute's share of the table, across its own column and those aliasing it, is its weight.
+	shares := make(map[Attribute]float64)
+	for i, attr := range table.Items {
+		shares[attr] += table.Prob[i]
+		shares[table.Items[table.Alias[i]]] += 1 - table.Prob[i]
+	}
+	for attr, w := range testWeights() {
+		if got := shares[attr] / float64(table.Len()); math.Abs(got-w) > 1e-9 {
+			t.Errorf("%s: expected a share of %v, got %v.", attr, w, got)
+		}
+	}
+	// Tables are built the same way every time.
+	for i := 0; i < 10; i++ {
+		again := m.Alias()
+		for j := range table.Items {
+			if again.Items[j] != table.Items[j] || again.Prob[j] != table.Prob[j] || again.Alias[j] != table.Alias[j] {
+				t.Fatalf("Alias table changed between builds.")
+			}
+		}
+	}
+	var empty AttributeWeightMap
+	if got := empty.Alias().Pick(0.5); got != (Attribute{}) {
+		t.Errorf("Expected an empty table to pick nothing, got %v.", got)
+	}
+	single := AttributeWeightMap{{TraitType: "Hat", Value: "Red"}: 3}
+	for _, f := range []float64{0, 0.5, 0.999999, 1} {
+		if got := single.Alias().Pick(f); got.Value != "Red" {
+			t.Errorf("Expected a single attribute to always be picked, got %v for %v.", got, f)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// Anchor is the point on an asset placed at its region's coordinates. X and Y are fractions of the width and height of the asset's image, from its top-left corner, so that {0.5, 0.5} is its center, and {0.5, 1.0} its bottom center. Anchors follow the asset through its region's Scale and Transform.
+type Anchor struct {
+	X, Y float64
+}
+
+// anchors maps the name of each named anchor to the anchor.
+var anchors = map[string]Anchor{
+	"center":        {0.5, 0.5},
+	"top-left":      {0, 0},
+	"top-center":    {0.5, 0},
+	"top-right":     {1, 0},
+	"center-left":   {0, 0.5},
+	"center-right":  {1, 0.5},
+	"bottom-left":   {0, 1},
+	"bottom-center": {0.5, 1},
+	"bottom-right":  {1, 1},
+}
+
+// ParseAnchor returns the anchor with the given name, such as "top-left" or "bottom-center". Returns an error if there is no such anchor.
+func ParseAnchor(name string) (Anchor, error) {
+	if a, ok := anchors[strings.ToLower(name)]; ok {
+		return a, nil
+	}
+	names := make([]string, 0, len(anchors))
+	for n := range anchors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Anchor{}, fmt.Errorf("Unknown anchor %q. Expected one of: %s; or a pair of fractions, [x, y].", name, strings.Join(names, ", "))
+}
+
+/*
+anchor returns the point on an asset, a, with image bounds, b, to place at the region's coordinates, in the asset's own coordinates: the region's Anchor, if it has one, or else the asset's Pivot. Returns false if neither is set, and the asset's composite should be centered instead.
+*/
+func (r *Region) anchor(a *Asset, b image.Rectangle) (x, y float64, ok bool) {
+	switch {
+	case r.Anchor != nil:
+		return float64(b.Min.X) + r.Anchor.X*float64(b.Dx()), float64(b.Min.Y) + r.Anchor.Y*float64(b.Dy()), true
+	case a.Pivot != nil:
+		return float64(b.Min.X + a.Pivot.X), float64(b.Min.Y + a.Pivot.Y), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,54 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAnchor(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	for _, test := range []struct {
+		region *Region
+		pivot  *image.Point
+		want   image.Rectangle // Where the child lands.
+	}{
+		// Centered, by default.
+		{&Region{}, nil, image.Rect(8, 9, 12, 11)},
+		{&Region{Anchor: &Anchor{0.5, 1}}, nil, image.Rect(8, 8, 12, 10)},
+		{&Region{Anchor: &Anchor{0, 0}}, nil, image.Rect(10, 10, 14, 12)},
+		// The asset's pivot, unless the region anchors it.
+		{&Region{}, &image.Point{4, 2}, image.Rect(6, 8, 10, 10)},
+		{&Region{Anchor: &Anchor{0, 0}}, &image.Point{4, 2}, image.Rect(10, 10, 14, 12)},
+		// Anchors follow scaling, and mirroring.
+		{&Region{Anchor: &Anchor{0.5, 1}, Scale: &Scale{2, 2}}, nil, image.Rect(6, 6, 14, 10)},
+		{&Region{Anchor: &Anchor{0, 0}, Scale: &Scale{-1, 1}}, nil, image.Rect(6, 10, 10, 12)},
+	} {
+		child := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 4; x++ {
+				child.SetNRGBA(x, y, red)
+			}
+		}
+		parent := &Asset{Image: image.NewNRGBA(image.Rect(0, 0, 20, 20))}
+		test.region.Coords = &image.Point{10, 10}
+		test.region.Asset = &Asset{Image: child, Pivot: test.pivot, Parent: test.region}
+		parent.Regions = []*Region{test.region}
+		comp, err := parent.Composite()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got image.Rectangle
+		b := comp.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if _, _, _, a := comp.At(x, y).RGBA(); a != 0 {
+					got = got.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if got != test.want {
+			t.Errorf("Child with anchor %+v, scale %+v and pivot %v landed at %v, expected %v.", test.region.Anchor, test.region.Scale, test.pivot, got, test.want)
+		}
+	}
+}
@@ -7,10 +7,10 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"math"
 	"os"
 
 	_ "golang.org/x/image/bmp"
-	_ "golang.org/x/image/draw"
 	_ "golang.org/x/image/riff"
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/vp8"
@@ -18,13 +18,22 @@ import (
 	_ "golang.org/x/image/webp"
 )
 
-// Asset is a type of image asset which has an asset type, Kind, a filepath, Path, an image, Image and a slice of available overlay regions, Regions.
+// Asset is an image asset: a trait, of a Kind and Name, which may be picked by Weight for a region, and the overlay regions of its own into which further assets are placed.
 type Asset struct {
-	Kind    string // @TODO: decide how this should be typed; Should this be many?
-	Path    string
-	Image   image.Image // @TODO: Consider embedding.
-	Parent  *Region
-	Regions []*Region
+	Kind        string       // The trait type of the attribute the asset gives a piece. @TODO: decide how this should be typed; Should this be many?
+	Name        string       // The trait value of the attribute the asset gives a piece.
+	DisplayType string       // The attribute's display type, if any.
+	Hidden      bool         // Leaves the attribute out of metadata.
+	Weight      float64      // The asset's weight among a region's candidates.
+	Path        string       // The image file.
+	Image       image.Image  // @TODO: Consider embedding.
+	Palette     *Palette     // Recolors the image when composited, if set.
+	Pivot       *image.Point // The point on the image, in pixels from its top-left corner, placed at a region's coordinates, unless the region sets its own Anchor.
+	Text        *Text        // Rendered for each piece, in place of an image file, if set.
+	Parent      *Region
+	Regions     []*Region
+	Parts       []*Region // Regions filled with images of their own, rather than picked: further pieces of the same trait, drawn in their own places, at their own Z, such as the back of a head of hair.
+	origin      *Asset    // The configured asset this was cloned from, if any.
 }
 
 func NewAsset() *Asset {
@@ -33,6 +42,70 @@ func NewAsset() *Asset {
 	}
 }
 
+// Attribute returns the attribute the asset gives a piece.
+func (a *Asset) Attribute() Attribute {
+	return Attribute{
+		TraitType:   a.Kind,
+		Value:       a.Name,
+		DisplayType: a.DisplayType,
+		Hidden:      a.Hidden,
+	}
+}
+
+// Key returns a key which identifies the asset by its kind, name and path, so that assets may be weighted in a WeightMap. Validate ensures no two assets of a configuration share a kind and name, so their keys, and the order in which they are picked, are always distinct.
+func (a *Asset) Key() string {
+	return a.Kind + "\x00" + a.Name + "\x00" + a.Path
+}
+
+// clone returns a copy of the asset, sharing its image, but without a parent or regions, ready to be placed into a composition tree. Its parts are copied along with it.
+func (a *Asset) clone() *Asset {
+	c := &Asset{
+		Kind:        a.Kind,
+		Name:        a.Name,
+		DisplayType: a.DisplayType,
+		Hidden:      a.Hidden,
+		Weight:      a.Weight,
+		Path:        a.Path,
+		Image:       a.Image,
+		Palette:     a.Palette,
+		Pivot:       a.Pivot,
+		Text:        a.Text,
+		Regions:     make([]*Region, 0),
+		origin:      a,
+	}
+	c.Parts = a.cloneParts()
+	return c
+}
+
+// cloneParts returns copies of the asset's parts, and their assets.
+func (a *Asset) cloneParts() []*Region {
+	if len(a.Parts) == 0 {
+		return nil
+	}
+	parts := make([]*Region, 0, len(a.Parts))
+	for _, part := range a.Parts {
+		pr := part.clone()
+		if part.Asset != nil {
+			pr.Asset = part.Asset.clone()
+			pr.Asset.Parent = pr
+		}
+		parts = append(parts, pr)
+	}
+	return parts
+}
+
+// Pick returns the asset chosen from candidates by weight, using f, a number in [0.0, 1.0). Assets with no weight are never picked. Returns nil if there is nothing to pick.
+func Pick(candidates []*Asset, f float64) *Asset {
+	weights := make([]float64, len(candidates))
+	for i, a := range candidates {
+		weights[i] = a.Weight
+	}
+	if i := pickIndex(weights, f); i >= 0 {
+		return candidates[i]
+	}
+	return nil
+}
+
 // Load loads an Asset's image into *Asset.Image. Returns an error if something went wrong along the way.
 func (a *Asset) Load() error {
 	// Check for a path.
@@ -48,8 +121,9 @@ func (a *Asset) Load() error {
 		logErr.Println(err)
 		return err
 	}
+	defer ifile.Close()
 	// Decode the image file. @TODO: consider allowing for selecting a frame from a GIF.
-	a.Image, format, err = image.Decode(ifile)
+	a.Image, _, err = image.Decode(ifile)
 	if err != nil {
 		err = fmt.Errorf("Failed to load asset image: Error while decoding %q: %s", a.Path, err)
 		logErr.Println(err)
@@ -67,54 +141,119 @@ func (a *Asset) IsLoaded() bool {
 	return true
 }
 
-// Composite climbs the current composition tree branch and composites down from the leaves. Returns image.Image, nil, when successful; nil, nil, at leaf; and nil/image.Image, error when there was a failure. @TODO: Find things to error about...
+/*
+Composite climbs the current composition tree branch and composites down from the leaves. The asset's image is composited with those of its regions at its native size, and the result is then scaled and transformed by its parent region's Scale and Transform, if any, with its Interpolation, growing to fit. Each region's composite, and each part's, is placed with its anchor on the region's coordinates, or centered on them if it has none, has the region's Effects applied, is clipped to the region's Clip mask, if any, and is blended onto the asset's by the region's Blend mode and Opacity.
+
+Where regions set a Z, each region's branch is still composited as a tree, but the sub-branches of regions with a Z of their own are pulled out, and drawn on their own onto one canvas, in order of Z, so that each blends with everything drawn beneath it. Each is placed, scaled and transformed as the full tree would place it, so that it may be drawn beneath its parent, or above branches further along the tree. A region's Effects and Clip apply to its branch less those pulled out of it, which take only its Opacity.
+
+Returns image.Image, nil, when successful; nil, nil, at leaf; and nil, error when there was a failure.
+*/
 func (a *Asset) Composite() (image.Image, error) {
+	if a.layered() {
+		return a.compositeDrawList()
+	}
+	comp, _, err := a.composite()
+	return comp, err
+}
+
+// composite composites the asset's branch as a tree, as Composite does. Also returns the point on the composite to place at its region's coordinates, or nil if it should be centered.
+func (a *Asset) composite() (image.Image, *image.Point, error) {
 	// Check asset. If nil, this region is a leaf.
 	if a == nil {
 		// Nothing to composite.
-		return nil, nil
+		return nil, nil, nil
 	}
 	// Not a leaf
-	// Get the current asset's bounds, well-formed.
-	abounds := a.Image.Bounds().Canon()
-	// Scale the branch asset if scale not zero or negative. @TODO: Consider implementing negative scaling for image inversion. Better yet, implement affine transformation.
-	if a.Parent.Scale != nil {
-		abounds := ScaleRectangle(a.Parent.Scale, orig)
-	}
-	// Create a new canvas to draw on and pass down the tree. We redraw the canvas to preserve the asset image. Fortunately, this happens outside the region loop.
-	canvas := image.NewNRGBA(abounds)
-	// Draw, and potentially scale, this branch asset onto the canvas.
-	draw.ApproxBiLinear.Scale(canvas, canvas.Bounds(), a.Image, a.Image.Bounds(), draw.Over, nil)
-	// Is this asset a leaf?
-	if a.Regions == nil {
-		return canvas, nil
-	}
-	// Climb the tree.
-	for _, region := range a.Regions {
-		comp, err := region.Composite()
+	if !a.IsLoaded() {
+		err := fmt.Errorf("Error compositing asset %q: image not loaded.", a.Name)
+		logErr.Println(err)
+		return nil, nil, err
+	}
+	// Create a new canvas to draw on and pass down the tree. We redraw the canvas to preserve the asset image, recolored, if need be.
+	img := a.image()
+	abounds := img.Bounds().Canon()
+	var canvas draw.Image = image.NewNRGBA(abounds)
+	draw.Draw(canvas, abounds, img, abounds.Min, draw.Src)
+	// Climb the tree, drawing the asset's parts first.
+	regions := make([]*Region, 0, len(a.Parts)+len(a.Regions))
+	regions = append(regions, a.Parts...)
+	for _, region := range append(regions, a.Regions...) {
+		if region == nil {
+			continue
+		}
+		comp, anchor, err := region.composite()
 		// Composition failed farther along the branch. Send it on down the tree.
 		if err != nil {
-			/*err := fmt.Errorf("Error compositing asset: %+v: %s", a, err)
-			logErr.Println(err)*/
-			return comp, err
+			return nil, nil, err
 		}
-		// Was at leaf. Send this image back down the tree.
+		// Was at leaf. Nothing to draw for this region.
 		if comp == nil {
-			return canvas, nil
+			continue
 		}
-		// Composite this asset with the branch composite.
-		// Get the branch composite bounds, well-formed.
-		cbounds := comp.Bounds().Canon()
+		// Composite this asset with the branch composite, placed on the region coordinates, with the region's effects and clip.
+		offset := region.place(comp.Bounds().Canon(), anchor, abounds)
+		comp = region.finish(comp, offset, img)
+		dst := comp.Bounds().Add(offset)
 		// Expand the current canvas if necessary.
-		if !cbounds.In(canvas.Bounds()) {
-			// Replace the old canvas with the new one.
-			canvas = GrowImage(canvas, cbounds)
+		canvas = GrowImage(canvas, dst)
+		// Blend the branch composite onto the canvas.
+		Blend(canvas, dst, comp, comp.Bounds().Min, region.Blend, region.opacity())
+	}
+	if a.Parent == nil {
+		return canvas, nil, nil
+	}
+	ax, ay, anchored := a.Parent.anchor(a, abounds)
+	// Pixel art anchors on whole pixels, so the asset's pixels stay on the grid.
+	if a.Parent.PixelArt {
+		ax, ay = math.Floor(ax), math.Floor(ay)
+	}
+	// Transform the branch composite, if its region mirrors, rotates or skews it. The anchor goes with it.
+	if a.Parent.affine() {
+		m, cbounds := a.Parent.Matrix(), canvas.Bounds()
+		cx, cy := float64(cbounds.Min.X+cbounds.Max.X)/2, float64(cbounds.Min.Y+cbounds.Max.Y)/2
+		ax, ay = m[0]*(ax-cx)+m[1]*(ay-cy), m[3]*(ax-cx)+m[4]*(ay-cy)
+		return TransformImage(canvas, m, a.Parent.interpolator()), roundAnchor(ax, ay, anchored), nil
+	}
+	// Scale the branch composite if scale not zero.
+	if a.Parent.Scale != nil {
+		cbounds := canvas.Bounds()
+		sbounds := ScaleRectangle(a.Parent.Scale.X, a.Parent.Scale.Y, cbounds)
+		if sbounds != cbounds {
+			scaled := image.NewNRGBA(sbounds)
+			a.Parent.interpolator().Scale(scaled, sbounds, canvas, cbounds, draw.Src, nil)
+			ax = float64(sbounds.Min.X) + (ax-float64(cbounds.Min.X))*float64(sbounds.Dx())/float64(cbounds.Dx())
+			ay = float64(sbounds.Min.Y) + (ay-float64(cbounds.Min.Y))*float64(sbounds.Dy())/float64(cbounds.Dy())
+			return scaled, roundAnchor(ax, ay, anchored), nil
 		}
-		// Center branch composite image on region coordinates.
-		offset := CenterOffset(region.Coordinates(), cbounds)
-		// Draw the asset onto the canvas.
-		draw.Over.Draw(canvas, canvas.Bounds(), comp, cbounds.Min.Add(offset))
 	}
 
-	return canvas, nil
+	return canvas, roundAnchor(ax, ay, anchored), nil
+}
+
+// place returns the offset by which to draw a branch composite, of bounds cbounds, in the region, on an asset's image, of bounds abounds: placing the branch's anchor, if any, on the region coordinates, or else centering it there.
+func (r *Region) place(cbounds image.Rectangle, anchor *image.Point, abounds image.Rectangle) image.Point {
+	coords := r.Coordinates(abounds)
+	if anchor != nil {
+		return coords.Sub(*anchor)
+	}
+	return CenterOffset(coords, cbounds).Sub(cbounds.Min)
+}
+
+// finish applies the region's effects to a branch composite, comp, to be drawn offset by offset onto an asset's image, img, keeping it where it was placed, and clips it to the region's mask, or to img. Returns the result.
+func (r *Region) finish(comp image.Image, offset image.Point, img image.Image) image.Image {
+	if len(r.Effects) > 0 {
+		comp = r.applyEffects(comp)
+	}
+	if r.Clip != nil {
+		comp = r.Clip.apply(comp, offset, img)
+	}
+	return comp
+}
+
+// roundAnchor returns the nearest pixel to an anchor at x, y, or nil if it is not anchored.
+func roundAnchor(x, y float64, anchored bool) *image.Point {
+	if !anchored {
+		return nil
+	}
+	return &image.Point{int(math.Round(x)), int(math.Round(y))}
 }
@@ -1,75 +1,41 @@
 package artwork
 
-import "sort"
+import "github.com/Jsewill/chia/nft/metadata"
 
+// Attribute is a trait of a piece, with a trait type, TraitType, and value, Value, as in CHIP-0007 metadata. DisplayType optionally hints at how the value should be displayed, and Hidden attributes are used for generation, but left out of metadata.
 type Attribute struct {
-	Name
+	TraitType   string
+	Value       string
+	DisplayType string
+	Hidden      bool
 }
 
-type AttributeWeightMap map[Attribute]float64
-
-// @TODO: Rework the WeightMap functions, either make them more generic, or make them based on an attribute type that is fairly generic/useful.
-
-// Sum returns the sum of the weight map.
-func (a AttributeWeightMap) Sum() (sum float64) {
-	for _, w := range a {
-		sum += w
-	}
-	return
+// Key returns a key which identifies the attribute by its trait type and value, and orders attributes stably.
+func (a Attribute) Key() string {
+	return a.TraitType + "\x00" + a.Value
 }
 
-/* Computes an array of intervals which represent the normalized, weighted distribution of an AttributeMap. The sum of the receiver, "a" should equal 1.0.
-Here's a visual:
-
-[]AttributeWeightMap{aV:0.2, aW:0.15, aX:0.25, aY:0.1, aZ:0.3}
-
-Would become something like,
+// String returns the attribute in the form "trait type: value".
+func (a Attribute) String() string {
+	return a.TraitType + ": " + a.Value
+}
 
-[]AttributeWeightIntervalMap{aV:0.2, aW:0.35, aX:0.60, aY:0.7, aZ:1.0}
+// Metadata returns the attribute as a CHIP-0007 metadata attribute.
+func (a Attribute) Metadata() *metadata.Attribute {
+	return &metadata.Attribute{Type: a.TraitType, Value: a.Value}
+}
 
-Order is irrelevant.
+// AttributeWeightMap maps attributes to their weights.
+type AttributeWeightMap = WeightMap[Attribute]
 
-This function returns the distribution as an *AttributeWeightInterval slice
-*/
-func (a AttributeWeightMap) Intervals() AttributeWeightIntervals {
-	// Make sure we have more than one weight.
-	if len(a) <= 1 {
-		// @TODO: Maybe this should return nil?
-		//return nil
-	}
-	// Make the CDF slice
-	prevSum := 0.0
-	i, ints := 0, make(AttributeWeightIntervals, len(a))
-	for attr, w := range a {
-		ints[i] = &AttributeWeightInterval{
-			Weight:    w + prevSum,
-			Attribute: attr,
-		}
-		prevSum += w
-		i++
-	}
-	// Sort the CDF slice
-	sort.Slice(ints, func(i, j int) bool {
-		return ints[i].Weight < ints[j].Weight
-	},
-	)
-	return ints
-}
+// AttributeWeightInterval is an attribute's upper bound in a weighted distribution.
+type AttributeWeightInterval = WeightInterval[Attribute]
 
-type AttributeWeightInterval struct {
-	Attribute
-	Weight float64
-}
+// AttributeWeightIntervals is a weighted distribution of attributes, as computed by AttributeWeightMap.Intervals.
+type AttributeWeightIntervals = WeightIntervals[Attribute]
 
-type AttributeWeightIntervals []*AttributeWeightInterval
+// AttributeAliasTable is an alias table of attributes, as computed by AttributeWeightMap.Alias.
+type AttributeAliasTable = AliasTable[Attribute]
 
-// Attribute returns the first attribute for which f is less than its computed distribution threshold. It assumes itself to be sorted.
-func (a AttributeWeightIntervals) Attribute(f float64) Attribute {
-	for _, awi := range a {
-		// Since slice is sorted, this is all we should need.
-		if f < awi.Weight {
-			return awi.Attribute
-		}
-	}
-	return 0
-}
+// AttributeSampler chooses attributes from a weighted distribution.
+type AttributeSampler = Sampler[Attribute]
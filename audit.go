@@ -0,0 +1,311 @@
+package artwork
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+// DefaultSignificance is the significance level at which an audit flags a region or trait as statistically off, unless told otherwise.
+const DefaultSignificance = 0.01
+
+// minExpected is the fewest placements a trait may be expected to get and still have a cell of its own in a chi-squared test. The test's approximation is poor for smaller cells.
+const minExpected = 5
+
+// TraitFlag flags a trait found to be amiss by an audit.
+type TraitFlag int
+
+const (
+	// TraitOK flags nothing amiss.
+	TraitOK TraitFlag = iota
+	// TraitOff flags a trait whose frequency strays further from its configured probability than chance allows, at the audit's significance level.
+	TraitOff
+	// TraitMissing flags a trait which never appeared, though, at the audit's significance level, it was too likely to be missing by chance.
+	TraitMissing
+)
+
+// String returns the name of the flag.
+func (f TraitFlag) String() string {
+	switch f {
+	case TraitOK:
+		return "ok"
+	case TraitOff:
+		return "off"
+	case TraitMissing:
+		return "missing"
+	}
+	return fmt.Sprintf("TraitFlag(%d)", int(f))
+}
+
+// Audit compares the configured probability of each trait, in each configured region, with the frequency at which it was actually picked in a collection. Significance is the significance level at which regions and traits are flagged.
+type Audit struct {
+	Significance float64
+	Regions      []*RegionAudit
+}
+
+/*
+RegionAudit is the audit of a configured region, Region, declared at Location. Placements is the number of times an asset was placed in the region across the collection, and Empty the number of times it was left empty. Configured holds the probability of each trait, from the normalized weights of the region's candidates, and Actual the share of placements each trait got.
+
+A chi-squared goodness-of-fit test compares the two: ChiSquared is the statistic, with DegreesOfFreedom, and PValue the probability of a fit at least as poor arising by chance. The region is Off if PValue falls below the audit's significance level. Traits expected fewer than five placements are pooled into one cell for the test, and Pooled is the number of them.
+*/
+type RegionAudit struct {
+	Region           *Region
+	Location         string
+	Placements       int
+	Empty            int
+	Configured       AttributeWeightMap
+	Actual           AttributeWeightMap
+	ChiSquared       float64
+	DegreesOfFreedom int
+	PValue           float64
+	Off              bool
+	Pooled           int
+	Traits           []*TraitAudit
+}
+
+// TraitAudit is the audit of a trait, Attribute, of an asset, Asset, in a region. Count is the number of times it was placed in the region, and Z its standard score: how many standard deviations Count lies from that expected, were it binomially distributed by its configured probability. Flag flags it if amiss.
+type TraitAudit struct {
+	Attribute
+	Asset      *Asset
+	Configured float64
+	Actual     float64
+	Count      int
+	Z          float64
+	Flag       TraitFlag
+}
+
+/*
+Audit compares the configured weights of each region's candidates against the assets actually picked for pieces, which must have been built from this configuration. Significance is the significance level, such as DefaultSignificance, at which regions and traits are flagged as statistically off. Traits which never appeared are flagged as missing, unless they were rare enough that chance allows it.
+
+Rules, conditions and quota allocation all, by design, move frequencies away from configured weights. Flags should be read with those in mind. Returns the *Audit.
+*/
+func (c *Configuration) Audit(pieces []*Piece, significance float64) *Audit {
+	// Tally the assets placed in each configured region.
+	counts := make(map[*Region]map[*Asset]int)
+	empty := make(map[*Region]int)
+	var tally func(regions []*Region)
+	tally = func(regions []*Region) {
+		for _, region := range regions {
+			t := region.template
+			if region.Asset == nil {
+				empty[t]++
+				continue
+			}
+			if counts[t] == nil {
+				counts[t] = make(map[*Asset]int)
+			}
+			counts[t][region.Asset.origin]++
+			tally(region.Asset.Regions)
+		}
+	}
+	for _, p := range pieces {
+		tally(p.Asset.Regions)
+	}
+	// The critical standard score for a two-tailed test at the significance level.
+	critical := math.Sqrt2 * math.Erfinv(1-significance)
+	a := &Audit{Significance: significance}
+	c.Walk(func(parent *Asset) {
+		location := "root"
+		if parent != c.Root {
+			location = describe(parent)
+		}
+		for _, t := range parent.Regions {
+			ra := &RegionAudit{
+				Region:     t,
+				Location:   location,
+				Empty:      empty[t],
+				Configured: make(AttributeWeightMap),
+				Actual:     make(AttributeWeightMap),
+			}
+			if pos, ok := c.sources[t]; ok {
+				ra.Location = fmt.Sprintf("%s (%s)", location, pos)
+			}
+			for _, n := range counts[t] {
+				ra.Placements += n
+			}
+			nm, _ := Weights(c.Candidates(t)).normalized()
+			n := float64(ra.Placements)
+			observed, expected := make([]float64, 0, len(nm)), make([]float64, 0, len(nm))
+			for _, asset := range nm.Items() {
+				p, count := nm[asset], counts[t][asset]
+				ta := &TraitAudit{
+					Attribute:  asset.Attribute(),
+					Asset:      asset,
+					Configured: p,
+					Count:      count,
+				}
+				if n > 0 {
+					ta.Actual = float64(count) / n
+					observed, expected = append(observed, float64(count)), append(expected, n*p)
+					if sd := math.Sqrt(n * p * (1 - p)); sd > 0 {
+						ta.Z = (float64(count) - n*p) / sd
+					}
+				}
+				switch {
+				case n > 0 && count == 0:
+					// Flag a missing trait only if it was unlikely to be missing by chance.
+					if math.Pow(1-p, n) < significance {
+						ta.Flag = TraitMissing
+					}
+				case math.Abs(ta.Z) > critical:
+					ta.Flag = TraitOff
+				}
+				ra.Configured[ta.Attribute] += ta.Configured
+				ra.Actual[ta.Attribute] += ta.Actual
+				ra.Traits = append(ra.Traits, ta)
+			}
+			ra.ChiSquared, ra.DegreesOfFreedom, ra.Pooled = chiSquared(observed, expected)
+			// One degree of freedom is lost to the fixed number of placements.
+			if ra.DegreesOfFreedom > 0 {
+				ra.DegreesOfFreedom--
+			}
+			ra.PValue = chiSquaredP(ra.ChiSquared, ra.DegreesOfFreedom)
+			ra.Off = ra.PValue < significance
+			a.Regions = append(a.Regions, ra)
+		}
+	})
+	return a
+}
+
+// Audit audits the generated pieces against the configuration, at a significance level, significance, as Configuration.Audit does.
+func (g *CollectionGenerator) Audit(significance float64) *Audit {
+	return g.Config.Audit(g.Pieces, significance)
+}
+
+// Flagged returns the audited traits which were flagged, in the order they were audited.
+func (a *Audit) Flagged() []*TraitAudit {
+	flagged := make([]*TraitAudit, 0)
+	for _, ra := range a.Regions {
+		for _, ta := range ra.Traits {
+			if ta.Flag != TraitOK {
+				flagged = append(flagged, ta)
+			}
+		}
+	}
+	return flagged
+}
+
+// OK reports whether the audit found nothing amiss: no region is off, and no trait flagged.
+func (a *Audit) OK() bool {
+	for _, ra := range a.Regions {
+		if ra.Off {
+			return false
+		}
+	}
+	return len(a.Flagged()) == 0
+}
+
+// WriteReport writes a plain text report of the audit to w, with a table of traits for each region.
+func (a *Audit) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, ra := range a.Regions {
+		verdict := "ok"
+		if ra.Off {
+			verdict = "OFF"
+		}
+		fmt.Fprintf(tw, "Region of kinds %v, in %s: %d placements, %d empty. Chi-squared %.3f, with %d degrees of freedom; p = %.4f, %s.\n", ra.Region.Kinds, ra.Location, ra.Placements, ra.Empty, ra.ChiSquared, ra.DegreesOfFreedom, ra.PValue, verdict)
+		if ra.Pooled > 0 {
+			fmt.Fprintf(tw, "Traits pooled for the chi-squared test, each expected fewer than %d placements: %d.\n", minExpected, ra.Pooled)
+		}
+		fmt.Fprintf(tw, "\ttrait\tconfigured\tactual\tcount\tz\tflag\n")
+		for _, ta := range ra.Traits {
+			fmt.Fprintf(tw, "\t%s\t%.2f%%\t%.2f%%\t%d\t%+.2f\t%s\n", ta.Attribute, ta.Configured*100, ta.Actual*100, ta.Count, ta.Z, ta.Flag)
+		}
+		fmt.Fprintln(tw)
+	}
+	flagged := len(a.Flagged())
+	fmt.Fprintf(tw, "%d traits flagged, at a significance level of %v.\n", flagged, a.Significance)
+	return tw.Flush()
+}
+
+// chiSquared computes the chi-squared statistic of observed counts against expected counts. Cells expected fewer than minExpected times are pooled into one, which is pooled in turn with the smallest of the rest, if still too small. Returns the statistic, the number of cells tested, and the number which were pooled.
+func chiSquared(observed, expected []float64) (x float64, cells, pooled int) {
+	type cell struct{ observed, expected float64 }
+	tested := make([]cell, 0, len(expected))
+	var small cell
+	for i, e := range expected {
+		if e < minExpected {
+			small.observed += observed[i]
+			small.expected += e
+			pooled++
+			continue
+		}
+		tested = append(tested, cell{observed[i], e})
+	}
+	if pooled > 0 {
+		if small.expected < minExpected && len(tested) > 0 {
+			s := 0
+			for i := range tested {
+				if tested[i].expected < tested[s].expected {
+					s = i
+				}
+			}
+			tested[s].observed += small.observed
+			tested[s].expected += small.expected
+		} else {
+			tested = append(tested, small)
+		}
+	}
+	for _, c := range tested {
+		if c.expected > 0 {
+			x += (c.observed - c.expected) * (c.observed - c.expected) / c.expected
+		}
+	}
+	return x, len(tested), pooled
+}
+
+// chiSquaredP returns the probability of a chi-squared statistic of at least x arising by chance, with k degrees of freedom.
+func chiSquaredP(x float64, k int) float64 {
+	if k <= 0 || x <= 0 {
+		return 1
+	}
+	return gammaQ(float64(k)/2, x/2)
+}
+
+// gammaQ computes the regularized upper incomplete gamma function, Q(a, x), by its series where x is small, and its continued fraction otherwise.
+func gammaQ(a, x float64) float64 {
+	const (
+		maxIterations = 1000
+		epsilon       = 1e-15
+		tiny          = 1e-300
+	)
+	lg, _ := math.Lgamma(a)
+	scale := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		// Sum the series for P(a, x), and take its complement.
+		term := 1 / a
+		sum := term
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*scale)
+	}
+	// Evaluate the continued fraction for Q(a, x), with Lentz's method.
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1; i < maxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return scale * h
+}
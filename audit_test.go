@@ -0,0 +1,112 @@
+package artwork
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChiSquaredP(t *testing.T) {
+	// Critical values of the chi-squared distribution.
+	cases := []struct {
+		x float64
+		k int
+		p float64
+	}{
+		{3.841, 1, 0.05},
+		{5.991, 2, 0.05},
+		{11.345, 3, 0.01},
+		{18.307, 10, 0.05},
+		{0.2107, 2, 0.9},
+	}
+	for _, c := range cases {
+		if p := chiSquaredP(c.x, c.k); math.Abs(p-c.p) > 1e-3 {
+			t.Errorf("chiSquaredP(%v, %d) = %v, expected %v.", c.x, c.k, p, c.p)
+		}
+	}
+}
+
+// auditPieces builds pieces from the configuration, c, n of each DNA in dnas, in no particular order.
+func auditPieces(t *testing.T, c *Configuration, dnas map[string]int) []*Piece {
+	t.Helper()
+	pieces := make([]*Piece, 0)
+	for dna, n := range dnas {
+		for i := 0; i < n; i++ {
+			p := NewPiece(uint(len(pieces)+1), nil, nil)
+			if err := p.BuildDNA(c, dna); err != nil {
+				t.Fatal(err)
+			}
+			pieces = append(pieces, p)
+		}
+	}
+	return pieces
+}
+
+func TestAudit(t *testing.T) {
+	c := testConfig()
+	// Every background is red, though blue is as likely, while hats fall as configured.
+	a := c.Audit(auditPieces(t, c, map[string]int{"0.2": 150, "0.3.4": 50}), DefaultSignificance)
+	if len(a.Regions) != 3 {
+		t.Fatalf("Expected 3 audited regions, the root's two and the crown's, got %d.", len(a.Regions))
+	}
+	for i, want := range []struct {
+		placements int
+		off        bool
+	}{
+		{200, true},
+		{200, false},
+		// The jewel is placed in every crown.
+		{50, false},
+	} {
+		if ra := a.Regions[i]; ra.Placements != want.placements || ra.Off != want.off {
+			t.Errorf("Region of kinds %v has %d placements, and is off: %v; expected %d, and %v.", ra.Region.Kinds, ra.Placements, ra.Off, want.placements, want.off)
+		}
+	}
+	flags := make(map[string]TraitFlag)
+	for _, ta := range a.Flagged() {
+		flags[ta.Attribute.String()] = ta.Flag
+	}
+	want := map[string]TraitFlag{
+		Attribute{TraitType: "background", Value: "Red"}.String():  TraitOff,
+		Attribute{TraitType: "background", Value: "Blue"}.String(): TraitMissing,
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("Flagged %v, expected %v.", flags, want)
+	}
+	if a.OK() {
+		t.Errorf("Expected the audit not to be OK.")
+	}
+	var report strings.Builder
+	if err := a.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Region of kinds [background], in root: 200 placements, 0 empty.", "OFF", "missing", "2 traits flagged, at a significance level of 0.01."} {
+		if !strings.Contains(report.String(), s) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", s, report.String())
+		}
+	}
+
+	// Backgrounds split evenly fit.
+	a = c.Audit(auditPieces(t, c, map[string]int{"0.2": 75, "0.3.4": 25, "1.2": 75, "1.3.4": 25}), DefaultSignificance)
+	if !a.OK() {
+		t.Errorf("Expected an audit of pieces as configured to be OK, got %d flagged.", len(a.Flagged()))
+	}
+
+	// A trait of 1000:1 odds is likely never to appear in 200 pieces, and is pooled, being expected in fewer than 5.
+	c.Assets[0].Weight, c.Assets[1].Weight = 1000, 1
+	a = c.Audit(auditPieces(t, c, map[string]int{"0.2": 150, "0.3.4": 50}), DefaultSignificance)
+	if !a.OK() {
+		t.Errorf("Expected a rare trait's absence to be OK, got %d flagged.", len(a.Flagged()))
+	}
+	if ra := a.Regions[0]; ra.Pooled != 1 || ra.DegreesOfFreedom != 0 {
+		t.Errorf("Expected the rare trait to be pooled, leaving no degrees of freedom, got %d pooled and %d.", ra.Pooled, ra.DegreesOfFreedom)
+	}
+	report.Reset()
+	if err := a.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if s := "Traits pooled for the chi-squared test, each expected fewer than 5 placements: 1."; !strings.Contains(report.String(), s) {
+		t.Errorf("Expected the report to contain %q, got:\n%s", s, report.String())
+	}
+}
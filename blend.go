@@ -0,0 +1,185 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BlendMode is a mode by which a layer's colors are blended with those beneath it, as defined by the W3C Compositing and Blending specification. Blending only affects where the layer covers what lies beneath; elsewhere, the layer is simply drawn over.
+type BlendMode int
+
+const (
+	// BlendNormal draws the layer over what lies beneath, as draw.Over does.
+	BlendNormal BlendMode = iota
+	// BlendMultiply multiplies the layer's colors with those beneath, darkening them.
+	BlendMultiply
+	// BlendScreen multiplies the complements of the layer's colors with those beneath, lightening them.
+	BlendScreen
+	// BlendOverlay multiplies or screens the layer's colors, depending on those beneath, deepening shadows and highlights beneath.
+	BlendOverlay
+	// BlendSoftLight darkens or lightens the colors beneath, depending on the layer's, like a diffuse spotlight.
+	BlendSoftLight
+	// BlendAdd adds the layer's colors to those beneath, clamping at white. Also known as linear dodge.
+	BlendAdd
+	// BlendDarken keeps the darker of the layer's colors and those beneath, channel by channel.
+	BlendDarken
+	// BlendLighten keeps the lighter of the layer's colors and those beneath, channel by channel.
+	BlendLighten
+	// BlendColorDodge brightens the colors beneath to reflect the layer's.
+	BlendColorDodge
+)
+
+// blendModes maps the name of each blend mode to the mode.
+var blendModes = map[string]BlendMode{
+	"normal":      BlendNormal,
+	"multiply":    BlendMultiply,
+	"screen":      BlendScreen,
+	"overlay":     BlendOverlay,
+	"soft-light":  BlendSoftLight,
+	"add":         BlendAdd,
+	"darken":      BlendDarken,
+	"lighten":     BlendLighten,
+	"color-dodge": BlendColorDodge,
+}
+
+// String returns the name of the blend mode.
+func (m BlendMode) String() string {
+	for name, bm := range blendModes {
+		if bm == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("BlendMode(%d)", int(m))
+}
+
+// ParseBlendMode returns the blend mode with the given name, as returned by BlendMode.String. Returns an error if there is no such mode.
+func ParseBlendMode(name string) (BlendMode, error) {
+	if m, ok := blendModes[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	names := make([]string, 0, len(blendModes))
+	for n := range blendModes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("Unknown blend mode %q. Expected one of: %s.", name, strings.Join(names, ", "))
+}
+
+// blend blends a channel of the layer, cs, with that beneath it, cb, both non-premultiplied and in [0.0, 1.0]. Returns the blended channel.
+func (m BlendMode) blend(cb, cs float64) float64 {
+	switch m {
+	case BlendMultiply:
+		return cb * cs
+	case BlendScreen:
+		return cb + cs - cb*cs
+	case BlendOverlay:
+		// Hard light, with the layers swapped.
+		if cb <= 0.5 {
+			return cs * 2 * cb
+		}
+		return BlendScreen.blend(cs, 2*cb-1)
+	case BlendSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		d := math.Sqrt(cb)
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case BlendAdd:
+		return math.Min(1, cb+cs)
+	case BlendDarken:
+		return math.Min(cb, cs)
+	case BlendLighten:
+		return math.Max(cb, cs)
+	case BlendColorDodge:
+		switch {
+		case cb == 0:
+			return 0
+		case cs >= 1:
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	}
+	return cs
+}
+
+/*
+Blend draws src, from sp, onto dst, within r, blending its colors with those of dst by a blend mode, mode, at an opacity, opacity, in [0.0, 1.0]. Colors are blended without premultiplied alpha, and the result composited source-over, so that translucent pixels of either image blend in proportion to their coverage.
+
+Normal blending is left to draw.Draw, and draw.DrawMask, where translucent. Otherwise, *image.NRGBA and *image.NRGBA64 images are read and written directly; any other draw.Image through its color model.
+*/
+func Blend(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, mode BlendMode, opacity float64) {
+	if opacity <= 0 {
+		return
+	}
+	if mode == BlendNormal {
+		if opacity >= 1 {
+			draw.Draw(dst, r, src, sp, draw.Over)
+			return
+		}
+		mask := image.NewUniform(color.Alpha16{uint16(math.Round(opacity * 0xffff))})
+		draw.DrawMask(dst, r, src, sp, mask, image.Point{}, draw.Over)
+		return
+	}
+	opacity = math.Min(1, opacity)
+	// Clip r to both images, as draw.Draw does. Delta maps points in dst to those in src.
+	delta := sp.Sub(r.Min)
+	r = r.Intersect(dst.Bounds()).Intersect(src.Bounds().Sub(delta))
+	var s, b [4]float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			s = nrgbaAt(src, x+delta.X, y+delta.Y)
+			as := s[3] * opacity
+			if as == 0 {
+				continue
+			}
+			b = nrgbaAt(dst, x, y)
+			ab := b[3]
+			// Source-over, with the layer's colors blended where they cover those beneath.
+			ao := as + ab*(1-as)
+			var o [4]float64
+			for i := 0; i < 3; i++ {
+				cs := (1-ab)*s[i] + ab*mode.blend(b[i], s[i])
+				o[i] = (as*cs + (1-as)*ab*b[i]) / ao
+			}
+			o[3] = ao
+			setNRGBA(dst, x, y, o)
+		}
+	}
+}
+
+// nrgbaAt returns the non-premultiplied color of img at x, y, as red, green, blue and alpha channels in [0.0, 1.0].
+func nrgbaAt(img image.Image, x, y int) [4]float64 {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		c := img.NRGBAAt(x, y)
+		return [4]float64{float64(c.R) / 0xff, float64(c.G) / 0xff, float64(c.B) / 0xff, float64(c.A) / 0xff}
+	case *image.NRGBA64:
+		c := img.NRGBA64At(x, y)
+		return [4]float64{float64(c.R) / 0xffff, float64(c.G) / 0xffff, float64(c.B) / 0xffff, float64(c.A) / 0xffff}
+	}
+	c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+	return [4]float64{float64(c.R) / 0xffff, float64(c.G) / 0xffff, float64(c.B) / 0xffff, float64(c.A) / 0xffff}
+}
+
+// setNRGBA sets the color of img at x, y, from non-premultiplied red, green, blue and alpha channels in [0.0, 1.0].
+func setNRGBA(img draw.Image, x, y int, c [4]float64) {
+	to8 := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 0xff))
+	}
+	to16 := func(v float64) uint16 {
+		return uint16(math.Round(math.Max(0, math.Min(1, v)) * 0xffff))
+	}
+	if img, ok := img.(*image.NRGBA); ok {
+		img.SetNRGBA(x, y, color.NRGBA{to8(c[0]), to8(c[1]), to8(c[2]), to8(c[3])})
+		return
+	}
+	img.Set(x, y, color.NRGBA64{to16(c[0]), to16(c[1]), to16(c[2]), to16(c[3])})
+}
@@ -0,0 +1,51 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	gray := color.NRGBA{128, 128, 128, 255}
+	red := color.NRGBA{255, 0, 0, 255}
+	for _, test := range []struct {
+		mode    BlendMode
+		opacity float64
+		src     color.NRGBA
+		x       int // The pixel to check: gray beneath at 0, clear at 1.
+		want    color.NRGBA
+	}{
+		{BlendNormal, 1, red, 0, red},
+		{BlendNormal, 0.5, red, 0, color.NRGBA{192, 64, 64, 255}},
+		{BlendMultiply, 1, red, 0, color.NRGBA{128, 0, 0, 255}},
+		{BlendScreen, 1, red, 0, color.NRGBA{255, 128, 128, 255}},
+		{BlendAdd, 1, red, 0, color.NRGBA{255, 128, 128, 255}},
+		{BlendDarken, 1, red, 0, color.NRGBA{128, 0, 0, 255}},
+		{BlendLighten, 1, red, 0, color.NRGBA{255, 128, 128, 255}},
+		{BlendMultiply, 0.5, red, 0, color.NRGBA{128, 64, 64, 255}},
+		// A clear layer leaves what lies beneath as it was.
+		{BlendMultiply, 1, color.NRGBA{}, 0, gray},
+		// Where nothing lies beneath, the layer is drawn as it is.
+		{BlendMultiply, 1, red, 1, red},
+	} {
+		dst := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		dst.SetNRGBA(0, 0, gray)
+		src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		src.SetNRGBA(0, 0, test.src)
+		src.SetNRGBA(1, 0, test.src)
+		Blend(dst, dst.Bounds(), src, image.Point{}, test.mode, test.opacity)
+		if got := dst.NRGBAAt(test.x, 0); !closeNRGBA(got, test.want) {
+			t.Errorf("Blending %v by %s, at %v, gave %v, expected %v.", test.src, test.mode, test.opacity, got, test.want)
+		}
+	}
+}
+
+// closeNRGBA reports whether two colors are within rounding of each other.
+func closeNRGBA(a, b color.NRGBA) bool {
+	near := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d >= -1 && d <= 1
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
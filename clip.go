@@ -0,0 +1,69 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+)
+
+// Clip clips a region's composite to a mask, so that it shows only where the mask is opaque: the alpha of the parent asset's image, or of a mask image, Mask, loaded from Path, if set. Masks are aligned with the top-left corner of the parent asset's image. Where Invert is set, the composite shows only where the mask is clear.
+type Clip struct {
+	Path   string
+	Mask   image.Image
+	Invert bool
+}
+
+// Load loads a clip's mask image from Path into Mask. Clips without a Path need no loading. Returns an error if something went wrong along the way.
+func (cl *Clip) Load() error {
+	if cl.Path == "" {
+		return nil
+	}
+	f, err := os.Open(cl.Path)
+	if err != nil {
+		err := fmt.Errorf("Failed to load clip mask: %s", err)
+		logErr.Println(err)
+		return err
+	}
+	defer f.Close()
+	cl.Mask, _, err = image.Decode(f)
+	if err != nil {
+		err = fmt.Errorf("Failed to load clip mask: Error while decoding %q: %s", cl.Path, err)
+		logErr.Println(err)
+		return err
+	}
+	return nil
+}
+
+/*
+apply clips a composite, comp, placed at offset from its own coordinates to those of its parent's canvas, to the clip's mask, or else the parent's image, parent. Returns the clipped composite, in its own coordinates.
+*/
+func (cl *Clip) apply(comp image.Image, offset image.Point, parent image.Image) *image.NRGBA {
+	mask := parent
+	if cl.Mask != nil {
+		mask = cl.Mask
+	}
+	// Align the mask with the parent's image.
+	shift := mask.Bounds().Min.Sub(parent.Bounds().Min)
+	mb := mask.Bounds()
+	cb := comp.Bounds()
+	clipped := image.NewNRGBA(cb)
+	for y := cb.Min.Y; y < cb.Max.Y; y++ {
+		for x := cb.Min.X; x < cb.Max.X; x++ {
+			c := color.NRGBAModel.Convert(comp.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			var ma uint32
+			if mp := image.Pt(x, y).Add(offset).Add(shift); mp.In(mb) {
+				_, _, _, ma = mask.At(mp.X, mp.Y).RGBA()
+			}
+			if cl.Invert {
+				ma = 0xffff - ma
+			}
+			c.A = uint8((uint32(c.A)*ma + 0x7fff) / 0xffff)
+			clipped.SetNRGBA(x, y, c)
+		}
+	}
+	return clipped
+}
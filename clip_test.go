@@ -0,0 +1,109 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClip(t *testing.T) {
+	// A parent opaque on its left half, a mask opaque on its right, and a child covering all of either.
+	parent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	mask := image.NewAlpha(image.Rect(0, 0, 4, 4))
+	child := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x < 2 {
+				parent.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			} else {
+				mask.SetAlpha(x, y, color.Alpha{255})
+			}
+			child.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+	for _, test := range []struct {
+		clip        *Clip
+		offset      image.Point
+		left, right uint8 // The alpha expected of the child's left and right edges.
+	}{
+		{&Clip{}, image.Point{}, 255, 0},
+		{&Clip{Invert: true}, image.Point{}, 0, 255},
+		{&Clip{Mask: mask}, image.Point{}, 0, 255},
+		// Placed further left, the child's left edge falls off the parent, and, two pixels over, its right edge on it.
+		{&Clip{}, image.Point{-1, 0}, 0, 0},
+		{&Clip{}, image.Point{-2, 0}, 0, 255},
+	} {
+		clipped := test.clip.apply(child, test.offset, parent)
+		if l, r := clipped.NRGBAAt(0, 0).A, clipped.NRGBAAt(3, 0).A; l != test.left || r != test.right {
+			t.Errorf("Clip %+v, at offset %v, left alpha %d on the left and %d on the right, expected %d and %d.", *test.clip, test.offset, l, r, test.left, test.right)
+		}
+	}
+}
+
+func TestPartClipMask(t *testing.T) {
+	dir := t.TempDir()
+	// A blue hat, with a red brim over it, masked to its right half.
+	half := image.NewAlpha(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			half.SetAlpha(x, y, color.Alpha{255})
+		}
+	}
+	for name, img := range map[string]image.Image{
+		"hat.png":  square(4, color.NRGBA{0, 0, 255, 255}),
+		"brim.png": square(4, color.NRGBA{255, 0, 0, 255}),
+		"half.png": half,
+	} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	config := `size: [8, 8]
+root:
+  regions:
+    - coords: [4, 4]
+      kinds: [hat]
+assets:
+  - kind: hat
+    name: Cap
+    path: hat.png
+    parts:
+      - path: brim.png
+        coords: [2, 2]
+        clip: {mask: half.png}
+`
+	c, err := ParseConfiguration([]byte(config), filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Assets[0].Parts[0].Clip.Mask == nil {
+		t.Fatal("Expected the part's clip mask to be loaded.")
+	}
+	p := NewPiece(1, nil, &c.Bounds)
+	if err := p.Build(c, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Composite(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		p    image.Point
+		want color.NRGBA
+	}{
+		{image.Pt(3, 4), color.NRGBA{0, 0, 255, 255}}, // The brim, masked away.
+		{image.Pt(5, 4), color.NRGBA{255, 0, 0, 255}}, // The brim, over the hat.
+	} {
+		if got := color.NRGBAModel.Convert(p.Asset.Image.At(test.p.X, test.p.Y)); got != test.want {
+			t.Errorf("At %v, got %v, expected %v.", test.p, got, test.want)
+		}
+	}
+}
@@ -1,7 +1,116 @@
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Jsewill/artwork"
+)
+
+// commands maps the name of each command to the function which runs it, given its arguments. Each returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"audit":  audit,
+	"reveal": reveal,
+}
 
 func main() {
-	fmt.Println("Artwork")
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd(os.Args[2:]))
+}
+
+// usage prints a summary of the commands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Artwork\n\nUsage:\n\n")
+	fmt.Fprintf(os.Stderr, "\tartwork audit -config <file> [-dir <output directory>] [-significance <level>]\n")
+	fmt.Fprintf(os.Stderr, "\t\tCompare the trait frequencies of a generated collection with the configured weights.\n")
+	fmt.Fprintf(os.Stderr, "\tartwork reveal -config <file> -seed <seed> -reveal-seed <seed> -entropy <entropy> [-dir <output directory>] [-name <name>] [-pattern <pattern>] [-rarity <method>]\n")
+	fmt.Fprintf(os.Stderr, "\t\tGive a generated collection's pieces their final token ids, from the committed reveal seed and public entropy, such as the hash of a block mined after minting closed.\n")
+}
+
+// audit audits a generated collection against its composition file, printing a report. Exits with 1 if anything was flagged.
+func audit(args []string) int {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	config := fs.String("config", "", "The composition file the collection was generated from.")
+	dir := fs.String("dir", artwork.DefaultOutDir, "The directory the collection was written to.")
+	significance := fs.Float64("significance", artwork.DefaultSignificance, "The significance level at which to flag regions and traits.")
+	fs.Parse(args)
+	if *config == "" {
+		fmt.Fprintln(os.Stderr, "A composition file is required.")
+		fs.Usage()
+		return 2
+	}
+	c, err := artwork.LoadFile(*config)
+	if err != nil {
+		return 2
+	}
+	pieces, err := artwork.ReadPieces(c, *dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(pieces) == 0 {
+		fmt.Fprintf(os.Stderr, "No pieces found in %q.\n", *dir)
+		return 2
+	}
+	a := c.Audit(pieces, *significance)
+	if err := a.WriteReport(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !a.OK() {
+		return 1
+	}
+	return 0
+}
+
+// reveal reveals a collection generated earlier, reading it back from its output directory. The collection's name, pattern, seed and rarity method must be those it was generated with.
+func reveal(args []string) int {
+	fs := flag.NewFlagSet("reveal", flag.ExitOnError)
+	config := fs.String("config", "", "The composition file the collection was generated from.")
+	dir := fs.String("dir", artwork.DefaultOutDir, "The directory the collection was written to.")
+	name := fs.String("name", "", "The name the collection was generated with.")
+	pattern := fs.String("pattern", artwork.DefaultPattern, "The naming pattern the collection was generated with.")
+	seed := fs.Int64("seed", 0, "The seed the collection was generated with.")
+	rarity := fs.String("rarity", artwork.StatisticalRarity.String(), "The rarity method the collection was generated with.")
+	revealSeed := fs.Int64("reveal-seed", 0, "The reveal seed committed to in the provenance record.")
+	entropy := fs.String("entropy", "", "Public entropy no one could choose or know at mint, such as the hash of a block mined after minting closed.")
+	fs.Parse(args)
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, required := range []string{"config", "seed", "reveal-seed", "entropy"} {
+		if !given[required] {
+			fmt.Fprintf(os.Stderr, "The -%s flag is required.\n", required)
+			fs.Usage()
+			return 2
+		}
+	}
+	method, err := artwork.ParseRarityMethod(*rarity)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	c, err := artwork.LoadFile(*config)
+	if err != nil {
+		return 2
+	}
+	g := artwork.NewCollectionGenerator(c, artwork.WithOutDir(*dir), artwork.WithName(*name), artwork.WithPattern(*pattern), artwork.WithSeed(*seed), artwork.WithRarityMethod(method))
+	if err := g.Load(); err != nil {
+		return 1
+	}
+	if _, err := g.Reveal(*revealSeed, *entropy); err != nil {
+		return 1
+	}
+	return 0
 }
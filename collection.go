@@ -0,0 +1,484 @@
+package artwork
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Jsewill/chia/nft"
+	"github.com/Jsewill/chia/nft/metadata"
+)
+
+const (
+	// DefaultOutDir is the directory to which a CollectionGenerator writes, unless told otherwise.
+	DefaultOutDir = "output"
+	// DefaultPattern is the naming pattern a CollectionGenerator uses, unless told otherwise.
+	DefaultPattern = "%d"
+	// DefaultMaxAttempts is the number of times a CollectionGenerator attempts to build a unique piece, unless told otherwise.
+	DefaultMaxAttempts = 1000
+	// MetadataFormat is the metadata format written for each piece.
+	MetadataFormat = "CHIP-0007"
+	// MintingTool identifies this package in piece metadata.
+	MintingTool = "artwork"
+	// RarityFile is the base name of the files, in the output directory, to which a CollectionGenerator writes the collection's rarity ranking, as CSV and JSON.
+	RarityFile = "rarity"
+)
+
+// CollectionGenerator generates a collection of unique Pieces from a Configuration, Config, writing their images and metadata, and implements Generator. Generating with the same Configuration and Seed reproduces the collection.
+type CollectionGenerator struct {
+	Config       *Configuration
+	Name         string       // The collection name, for naming pieces in their metadata.
+	Supply       uint         // The number of pieces, counting the configuration's one of ones, which are placed among those generated.
+	OutDir       string       // The directory to which images and metadata are written.
+	Pattern      string       // A fmt pattern which, given a piece's id, produces the base name of its files.
+	Seed         int64        // Determines every piece's picks.
+	MaxAttempts  uint         // How many times a piece duplicating another is rebuilt, or, under quota allocation, has traits swapped with other pieces.
+	Allocation   Allocation   // Whether traits are picked at random by weight, or dealt out from exact quotas.
+	RarityMethod RarityMethod // How pieces are ranked, before their metadata is written.
+	RevealSeed   *int64       // The seed the provenance record commits to, if set, for a later Reveal.
+	Pieces       []*Piece     // Once generated, the pieces, with their images released, and each Path set to its image file.
+	Rarities     Rarities     // Once generated, the pieces' ranking.
+	Provenance   *Provenance  // Once generated, the collection's provenance record.
+}
+
+// GeneratorOption is a function which configures a *CollectionGenerator.
+type GeneratorOption func(g *CollectionGenerator)
+
+// WithName sets the collection name, used for naming pieces in their metadata.
+func WithName(name string) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.Name = name
+	}
+}
+
+// WithSupply sets the number of pieces to generate.
+func WithSupply(n uint) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.Supply = n
+	}
+}
+
+// WithOutDir sets the directory to which images and metadata are written.
+func WithOutDir(dir string) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.OutDir = dir
+	}
+}
+
+// WithPattern sets the fmt pattern used to name each piece's files, given its id. For example, "piece-%05d" names piece 7's image "piece-00007.png".
+func WithPattern(pattern string) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.Pattern = pattern
+	}
+}
+
+// WithSeed sets the collection seed, from which each piece's random picks are derived.
+func WithSeed(seed int64) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.Seed = seed
+	}
+}
+
+// WithMaxAttempts sets the number of times to attempt building each piece, before giving up on finding one which is unique.
+func WithMaxAttempts(n uint) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.MaxAttempts = n
+	}
+}
+
+// WithAllocation sets the method of allocating traits to pieces.
+func WithAllocation(a Allocation) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.Allocation = a
+	}
+}
+
+// WithRarityMethod sets the method by which pieces are ranked by rarity.
+func WithRarityMethod(m RarityMethod) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.RarityMethod = m
+	}
+}
+
+// WithRevealSeed sets the seed from which the final token ids are determined on Reveal. The provenance record commits to the seed, so it should be hard to guess, and kept secret until the reveal.
+func WithRevealSeed(seed int64) GeneratorOption {
+	return func(g *CollectionGenerator) {
+		g.RevealSeed = &seed
+	}
+}
+
+// NewCollectionGenerator creates a new *CollectionGenerator for the Configuration, c, with a supply of one piece, written to DefaultOutDir using DefaultPattern, and seeded from the current time, unless otherwise configured by opts.
+func NewCollectionGenerator(c *Configuration, opts ...GeneratorOption) *CollectionGenerator {
+	g := &CollectionGenerator{
+		Config:      c,
+		Supply:      1,
+		OutDir:      DefaultOutDir,
+		Pattern:     DefaultPattern,
+		Seed:        time.Now().UnixNano(),
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Generate builds and composites each piece of the collection, writing its image and metadata to the output directory, along with the collection's rarity ranking and provenance record. Returns an nft.Collection with an Nft for each piece, carrying its file paths and hashes, ready to mint; or an error on the first failure.
+func (g *CollectionGenerator) Generate() (nft.Collection, error) {
+	var c nft.Collection
+	if g.Config == nil {
+		err := fmt.Errorf("Failed to generate collection: no configuration.")
+		logErr.Println(err)
+		return c, err
+	}
+	if err := g.Config.Validate(); err != nil {
+		err := fmt.Errorf("Failed to generate collection: invalid configuration:\n%s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	if err := os.MkdirAll(g.OutDir, 0755); err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	// Place any one of ones, leaving the rest of the supply to generate.
+	oneOfOnes, ids, err := g.placeOneOfOnes()
+	if err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	// Make sure the configuration can produce enough unique pieces.
+	combinations, err := g.Config.Combinations()
+	if err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	if combinations.Cmp(new(big.Int).SetUint64(uint64(len(ids)))) < 0 {
+		err := fmt.Errorf("Failed to generate collection: %d pieces are to be generated, but the configuration allows at most %s unique combinations.", len(ids), combinations)
+		logErr.Println(err)
+		return c, err
+	}
+	log.Printf("Generating %d pieces, of at most %s unique combinations, with seed %d and %s allocation, alongside %d one of ones.\n", len(ids), combinations, g.Seed, g.Allocation, len(oneOfOnes))
+	// Build every piece before compositing any, as quota allocation may yet swap traits between them.
+	var generated []*Piece
+	if g.Allocation == Quota {
+		generated, err = g.allocate(ids)
+	} else {
+		generated, err = g.buildAll(ids)
+	}
+	if err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s\nThe configuration allows at most %s unique combinations.", err, combinations)
+		logErr.Println(err)
+		return c, err
+	}
+	// Rank the generated pieces, so their rarity can go in their metadata. One of ones are left out, as their traits are their own.
+	g.Rarities = Rank(generated, g.RarityMethod)
+	// Put the one of ones in their places.
+	pieces := make([]*Piece, 0, g.Supply)
+	pieces = append(pieces, generated...)
+	for _, p := range oneOfOnes {
+		pieces = append(pieces, p)
+	}
+	sort.Slice(pieces, func(i, j int) bool {
+		return pieces[i].Id < pieces[j].Id
+	})
+	g.Pieces = make([]*Piece, 0, g.Supply)
+	for _, p := range pieces {
+		if p.OneOfOne == nil {
+			if err := p.Composite(); err != nil {
+				err := fmt.Errorf("Failed to generate collection: %s", err)
+				logErr.Println(err)
+				return c, err
+			}
+		}
+		n, err := g.write(p)
+		if err != nil {
+			err := fmt.Errorf("Failed to generate collection: %s", err)
+			logErr.Println(err)
+			return c, err
+		}
+		g.Pieces = append(g.Pieces, p)
+		c.NFTs = append(c.NFTs, n)
+	}
+	if err := g.writeRarities(); err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	g.Provenance = g.provenance(c)
+	if err := g.writeProvenance(); err != nil {
+		err := fmt.Errorf("Failed to generate collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	log.Printf("Generated %d pieces in %q, with provenance hash %s.\n", len(g.Pieces), g.OutDir, g.Provenance.Hash)
+	g.logMarginals()
+	return c, nil
+}
+
+// Marginals returns the share of the generated pieces, leaving out one of ones, with each trait, from 0.0 to 1.0, as an AttributeWeightMap. These are the weights traits actually ended up with, once rules, conditions and chance have had their way, and may differ from those configured. A trait is counted once per piece, however many times it appears.
+func (g *CollectionGenerator) Marginals() AttributeWeightMap {
+	return Frequencies(g.generated())
+}
+
+// generated returns the generated pieces, leaving out any one of ones.
+func (g *CollectionGenerator) generated() []*Piece {
+	pieces := make([]*Piece, 0, len(g.Pieces))
+	for _, p := range g.Pieces {
+		if p.OneOfOne == nil {
+			pieces = append(pieces, p)
+		}
+	}
+	return pieces
+}
+
+// logMarginals logs the share of generated pieces with each trait.
+func (g *CollectionGenerator) logMarginals() {
+	m := g.Marginals()
+	log.Printf("Trait distribution over %d generated pieces:\n", len(g.generated()))
+	for _, attr := range m.Items() {
+		log.Printf("    %s: %.2f%%\n", attr, m[attr]*100)
+	}
+}
+
+// buildAll builds a piece for each of ids, picking traits at random, by weight. Returns the pieces, or an error if any could not be built uniquely.
+func (g *CollectionGenerator) buildAll(ids []uint) ([]*Piece, error) {
+	pieces := make([]*Piece, 0, len(ids))
+	seen := make(map[string]uint)
+	for _, id := range ids {
+		p, err := g.buildUnique(id, seen)
+		if err != nil {
+			return nil, fmt.Errorf("%s %d of the %d requested were built.", err, len(pieces), len(ids))
+		}
+		pieces = append(pieces, p)
+	}
+	return pieces, nil
+}
+
+// buildUnique builds piece, id, rebuilding it until its DNA is not among those already seen, or MaxAttempts is reached. Returns the *Piece, having recorded its DNA hash in seen, or an error if no unique piece could be built.
+func (g *CollectionGenerator) buildUnique(id uint, seen map[string]uint) (*Piece, error) {
+	p := g.newPiece(id)
+	// Every attempt draws from the same source, so rebuilding remains reproducible.
+	pick := &randomPicker{rand: rand.New(rand.NewSource(PieceSeed(g.Seed, id)))}
+	for attempt := uint(1); attempt <= g.MaxAttempts; attempt++ {
+		if err := p.buildWith(g.Config, pick); err != nil {
+			return nil, err
+		}
+		hash := DNAHash(p.DNA)
+		if dup, ok := seen[hash]; ok {
+			log.Printf("Piece #%d duplicates piece #%d (DNA %q). Rebuilding.\n", id, dup, p.DNA)
+			continue
+		}
+		seen[hash] = id
+		return p, nil
+	}
+	return nil, fmt.Errorf("Could not build a unique piece #%d in %d attempts.", id, g.MaxAttempts)
+}
+
+// Render rebuilds and composites a single piece, id, from its DNA, without writing it. Returns the composited *Piece, or an error if the DNA does not fit the configuration.
+func (g *CollectionGenerator) Render(id uint, dna string) (*Piece, error) {
+	p := g.newPiece(id)
+	if err := p.BuildDNA(g.Config, dna); err != nil {
+		return nil, err
+	}
+	if err := p.Composite(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadPieces reads back the generated pieces of a collection generated from c, and written to a directory, dir, rebuilding each from the DNA in its metadata. Pieces are not composited, and one of ones are left out. Returns the pieces, ordered by id, or an error if any metadata could not be read, or its DNA does not fit c.
+func ReadPieces(c *Configuration, dir string) ([]*Piece, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	pieces := make([]*Piece, 0, len(paths))
+	for _, path := range paths {
+		if base := filepath.Base(path); base == RarityFile+".json" || base == ProvenanceFile+".json" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read piece metadata: %s", err)
+		}
+		var md metadata.Metadata
+		if err := json.Unmarshal(b, &md); err != nil {
+			return nil, fmt.Errorf("Failed to read piece metadata %q: %s", path, err)
+		}
+		data, _ := md.Data.(map[string]any)
+		if oneOfOne, _ := data["one_of_one"].(bool); oneOfOne {
+			continue
+		}
+		dna, ok := data["dna"].(string)
+		if !ok {
+			return nil, fmt.Errorf("Failed to read piece metadata %q: no DNA found.", path)
+		}
+		p := NewPiece(md.EditionNumber, nil, nil)
+		if err := p.BuildDNA(c, dna); err != nil {
+			return nil, fmt.Errorf("Failed to read piece metadata %q: %s", path, err)
+		}
+		pieces = append(pieces, p)
+	}
+	sort.Slice(pieces, func(i, j int) bool {
+		return pieces[i].Id < pieces[j].Id
+	})
+	return pieces, nil
+}
+
+// newPiece creates a new *Piece with a blank canvas of the configured bounds, if the root asset has no image to use instead.
+func (g *CollectionGenerator) newPiece(id uint) *Piece {
+	if g.Config.Root.IsLoaded() {
+		return NewPiece(id, nil, nil)
+	}
+	return NewPiece(id, nil, &g.Config.Bounds)
+}
+
+// write writes a piece's image and metadata to the output directory, releasing its image. A one of one's image is copied as it is. Returns an *nft.Nft for the piece.
+func (g *CollectionGenerator) write(p *Piece) (*nft.Nft, error) {
+	base := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, p.Id))
+	// Write the image.
+	var imgPath, imgHash string
+	var err error
+	if p.OneOfOne != nil {
+		imgPath, imgHash, err = g.writeOneOfOne(p)
+	} else {
+		imgPath = base + ".png"
+		imgHash, err = writeHashed(imgPath, func(w io.Writer) error {
+			return png.Encode(w, p.Image)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Path, p.Image = imgPath, nil
+	metaPath, metaHash, err := g.writeMetadata(p, "")
+	if err != nil {
+		return nil, err
+	}
+	return &nft.Nft{
+		Asset:    &nft.Asset{Uris: []string{imgPath}, Hash: imgHash},
+		Metadata: &nft.Asset{Uris: []string{metaPath}, Hash: metaHash},
+		License:  &nft.Asset{},
+	}, nil
+}
+
+// writeMetadata writes a piece's metadata to the output directory, with a suffix, if any, appended to its file name. Returns the path, less the suffix, and hex encoded SHA-256 hash of the file.
+func (g *CollectionGenerator) writeMetadata(p *Piece, suffix string) (string, string, error) {
+	path := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, p.Id)) + ".json"
+	hash, err := writeHashed(path+suffix, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(g.Metadata(p))
+	})
+	return path, hash, err
+}
+
+// Metadata returns the CHIP-0007 metadata for a piece of this collection. Its data carries the piece's DNA, and its rarity, once ranked. One of ones carry their own name, description, attributes and data instead.
+func (g *CollectionGenerator) Metadata(p *Piece) *metadata.Metadata {
+	name := fmt.Sprintf("#%d", p.Id)
+	if g.Name != "" {
+		name = fmt.Sprintf("%s #%d", g.Name, p.Id)
+	}
+	if p.OneOfOne != nil {
+		return g.oneOfOneMetadata(p, name)
+	}
+	data := map[string]any{"dna": p.DNA}
+	if p.Rarity != nil {
+		data["rarity"] = map[string]any{
+			"method": p.Rarity.Method.String(),
+			"score":  p.Rarity.Score,
+			"rank":   p.Rarity.Rank,
+		}
+	}
+	return &metadata.Metadata{
+		Format:        MetadataFormat,
+		Name:          name,
+		MintingTool:   MintingTool,
+		EditionNumber: p.Id,
+		EditionTotal:  g.Supply,
+		SeriesNumber:  p.Id,
+		SeriesTotal:   g.Supply,
+		Attributes:    p.MetadataAttributes(),
+		Data:          data,
+	}
+}
+
+// oneOfOneMetadata returns the CHIP-0007 metadata for a one of one piece, named name unless the one of one has a name of its own. Its data is marked "one_of_one", so that it is not mistaken for a generated piece.
+func (g *CollectionGenerator) oneOfOneMetadata(p *Piece, name string) *metadata.Metadata {
+	o := p.OneOfOne
+	if o.Name != "" {
+		name = o.Name
+	}
+	data := map[string]any{"one_of_one": true}
+	for k, v := range o.Data {
+		data[k] = v
+	}
+	return &metadata.Metadata{
+		Format:        MetadataFormat,
+		Name:          name,
+		Description:   o.Description,
+		MintingTool:   MintingTool,
+		EditionNumber: p.Id,
+		EditionTotal:  g.Supply,
+		SeriesNumber:  p.Id,
+		SeriesTotal:   g.Supply,
+		Attributes:    p.MetadataAttributes(),
+		Data:          data,
+	}
+}
+
+// writeRarities writes the collection's rarity ranking to the output directory, as CSV and JSON.
+func (g *CollectionGenerator) writeRarities() error {
+	base := filepath.Join(g.OutDir, RarityFile)
+	if _, err := writeHashed(base+".csv", g.Rarities.WriteCSV); err != nil {
+		return err
+	}
+	if _, err := writeHashed(base+".json", g.Rarities.WriteJSON); err != nil {
+		return err
+	}
+	return nil
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("Failed to read %q: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeHashed creates a file at path, and writes to it with f. Returns the hex encoded SHA-256 hash of what was written.
+func writeHashed(path string, f func(w io.Writer) error) (string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if err := f(io.MultiWriter(file, h)); err != nil {
+		return "", fmt.Errorf("Failed to write %q: %s", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("Failed to write %q: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
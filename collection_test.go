@@ -0,0 +1,104 @@
+package artwork
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jsewill/chia/nft/metadata"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	g := NewCollectionGenerator(testConfig(), WithName("Test"), WithSupply(3), WithOutDir(dir), WithPattern("piece-%02d"), WithSeed(1))
+	c, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.NFTs) != 3 || len(g.Pieces) != 3 {
+		t.Fatalf("Expected 3 pieces, got %d NFTs, and %d pieces.", len(c.NFTs), len(g.Pieces))
+	}
+	for i, p := range g.Pieces {
+		if p.Id != uint(i+1) {
+			t.Errorf("Piece %d has id %d.", i, p.Id)
+		}
+		if p.Image != nil {
+			t.Errorf("Piece #%d still holds its image.", p.Id)
+		}
+		n := c.NFTs[i]
+		if want := filepath.Join(dir, fmt.Sprintf("piece-%02d.png", i+1)); p.Path != want || n.Asset.Uris[0] != want {
+			t.Errorf("Piece #%d was written to %q, and %q, expected %q.", p.Id, p.Path, n.Asset.Uris[0], want)
+		}
+		if n.Asset.Hash == "" || n.Metadata.Hash == "" {
+			t.Errorf("Piece #%d is missing its hashes.", p.Id)
+		}
+		b, err := os.ReadFile(n.Metadata.Uris[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var md metadata.Metadata
+		if err := json.Unmarshal(b, &md); err != nil {
+			t.Fatal(err)
+		}
+		if md.Name != fmt.Sprintf("Test #%d", i+1) || md.EditionNumber != p.Id || md.EditionTotal != 3 || len(md.Attributes) != len(p.Traits) {
+			t.Errorf("Piece #%d has unexpected metadata: %+v", p.Id, md)
+		}
+		if data, _ := md.Data.(map[string]any); data["dna"] != p.DNA {
+			t.Errorf("Piece #%d metadata has DNA %v, expected %q.", p.Id, data["dna"], p.DNA)
+		}
+	}
+	for _, name := range []string{RarityFile + ".csv", RarityFile + ".json", ProvenanceFile + ".json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be written: %s", name, err)
+		}
+	}
+	// Pieces read back from their metadata match those generated.
+	pieces, err := ReadPieces(g.Config, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range pieces {
+		if p.Id != g.Pieces[i].Id || p.DNA != g.Pieces[i].DNA {
+			t.Errorf("Read back piece #%d with DNA %q, expected #%d with %q.", p.Id, p.DNA, g.Pieces[i].Id, g.Pieces[i].DNA)
+		}
+	}
+
+	if _, err := NewCollectionGenerator(nil, WithOutDir(dir)).Generate(); err == nil {
+		t.Error("Expected generating without a configuration to fail.")
+	}
+	invalid := testConfig()
+	invalid.Assets[0].Weight = -1
+	if _, err := NewCollectionGenerator(invalid, WithOutDir(dir)).Generate(); err == nil {
+		t.Error("Expected generating from an invalid configuration to fail.")
+	}
+}
+
+func TestUnique(t *testing.T) {
+	// Every one of the 4 combinations is needed.
+	g := NewCollectionGenerator(testConfig(), WithSupply(4), WithOutDir(t.TempDir()), WithSeed(3))
+	if _, err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, p := range g.Pieces {
+		if seen[p.DNA] {
+			t.Errorf("Piece #%d duplicates another, with DNA %q.", p.Id, p.DNA)
+		}
+		seen[p.DNA] = true
+	}
+	// One more is too many.
+	g = NewCollectionGenerator(testConfig(), WithSupply(5), WithOutDir(t.TempDir()))
+	_, err := g.Generate()
+	if err == nil || !strings.Contains(err.Error(), "at most 4 unique combinations") {
+		t.Errorf("Expected a supply of 5 to be too many for 4 combinations, got %v.", err)
+	}
+	// A single attempt per piece is not enough to find them all.
+	g = NewCollectionGenerator(testConfig(), WithSupply(4), WithOutDir(t.TempDir()), WithSeed(3), WithMaxAttempts(1))
+	_, err = g.Generate()
+	if err == nil || !strings.Contains(err.Error(), "Could not build a unique piece") {
+		t.Errorf("Expected generating with a single attempt per piece to fail, got %v.", err)
+	}
+}
@@ -0,0 +1,55 @@
+package artwork
+
+import (
+	"fmt"
+	"math"
+)
+
+// Condition makes traits, Then, more or less likely to be picked, by multiplying their weights by Factor, in any piece where a trait, If, has already been picked. For example, Condition{TraitRef{"background", "Gold"}, TraitRef{"accessory", "Gold Chain"}, 3} makes a gold chain three times likelier on a gold background. Traits are picked in the order regions are built, depth first, so If must be picked in a region built before the one in which Then is picked.
+type Condition struct {
+	If     TraitRef
+	Then   TraitRef
+	Factor float64
+}
+
+// String describes the condition, as in "background/Gold weighs accessory/* by 3".
+func (cd *Condition) String() string {
+	return fmt.Sprintf("%s weighs %s by %v", cd.If, cd.Then, cd.Factor)
+}
+
+// conditionalWeights returns the weights of candidates, with the factors of any conditions met by the traits already picked applied. Returns the weights, and whether any condition applied to them. Weights are only built if a condition applies; otherwise, they are nil.
+func (c *Configuration) conditionalWeights(picked, candidates []*Asset) (WeightMap[*Asset], bool) {
+	var weights WeightMap[*Asset]
+	for _, cd := range c.Conditions {
+		if !containsMatch(picked, cd.If) {
+			continue
+		}
+		for _, a := range candidates {
+			if cd.Then.Matches(a) {
+				if weights == nil {
+					weights = Weights(candidates)
+				}
+				weights[a] *= cd.Factor
+			}
+		}
+	}
+	return weights, weights != nil
+}
+
+// checkConditions checks the configured conditions: each must refer to configured traits, and have a finite, non-negative factor.
+func (c *Configuration) checkConditions() Errors {
+	var errs Errors
+	for _, cd := range c.Conditions {
+		if math.IsNaN(cd.Factor) || math.IsInf(cd.Factor, 0) || cd.Factor < 0 {
+			errs = append(errs, c.errorf(cd, "Condition %q has an invalid factor, %v. Factors must be finite and non-negative.", cd, cd.Factor))
+		}
+		for _, t := range []TraitRef{cd.If, cd.Then} {
+			if t.Kind == "" {
+				errs = append(errs, c.errorf(cd, "Condition %q refers to a trait with no kind.", cd))
+			} else if len(c.matching(t)) == 0 {
+				errs = append(errs, c.errorf(cd, "Condition %q refers to %s, which matches no weighted asset.", cd, t))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,112 @@
+package artwork
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConditionalWeights(t *testing.T) {
+	c := testConfig()
+	red, blue := testAsset(c, "background", "Red"), testAsset(c, "background", "Blue")
+	cap, crown := testAsset(c, "hat", "Cap"), testAsset(c, "hat", "Crown")
+	c.Conditions = []*Condition{
+		{TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}, 3},
+		// A reference without a name weighs every trait of its kind.
+		{TraitRef{"background", "Blue"}, TraitRef{"hat", ""}, 0.5},
+	}
+	for _, test := range []struct {
+		picked     []*Asset
+		candidates []*Asset
+		want       WeightMap[*Asset]
+	}{
+		{nil, []*Asset{cap, crown}, nil},
+		{[]*Asset{red}, []*Asset{cap, crown}, WeightMap[*Asset]{cap: 0.75, crown: 0.75}},
+		{[]*Asset{blue}, []*Asset{cap, crown}, WeightMap[*Asset]{cap: 0.375, crown: 0.125}},
+		// Conditions on traits which are not candidates change nothing.
+		{[]*Asset{red}, []*Asset{cap}, nil},
+	} {
+		weights, conditional := c.conditionalWeights(test.picked, test.candidates)
+		if conditional != (test.want != nil) {
+			t.Errorf("With %v picked, expected a condition to apply: %v.", traitNamesOf(test.picked), test.want != nil)
+		}
+		if len(weights) != len(test.want) {
+			t.Errorf("With %v picked, got weights %v, expected %v.", traitNamesOf(test.picked), weights, test.want)
+			continue
+		}
+		for a, w := range test.want {
+			if weights[a] != w {
+				t.Errorf("With %v picked, %s/%s has weight %v, expected %v.", traitNamesOf(test.picked), a.Kind, a.Name, weights[a], w)
+			}
+		}
+	}
+}
+
+// traitNamesOf returns the kind and name of each of assets.
+func traitNamesOf(assets []*Asset) []string {
+	names := make([]string, 0, len(assets))
+	for _, a := range assets {
+		names = append(names, a.Kind+"/"+a.Name)
+	}
+	return names
+}
+
+func TestConditionalBuild(t *testing.T) {
+	c := testConfig()
+	c.Conditions = []*Condition{
+		{TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}, 0},
+		{TraitRef{"background", "Blue"}, TraitRef{"hat", "Crown"}, 3},
+	}
+	// On blue, the crown is as likely as the cap; on red, it is never picked.
+	const n = 4000
+	counts := make(map[string]int)
+	for seed := int64(0); seed < n; seed++ {
+		p := NewPiece(1, nil, nil)
+		if err := p.Build(c, rand.New(rand.NewSource(seed))); err != nil {
+			t.Fatal(err)
+		}
+		names := traitNames(p)
+		counts[names[0]+" "+names[1]]++
+	}
+	if k := counts["background/Red hat/Crown"]; k > 0 {
+		t.Errorf("Expected no crowns on red, got %d.", k)
+	}
+	crowns, blues := counts["background/Blue hat/Crown"], counts["background/Blue hat/Crown"]+counts["background/Blue hat/Cap"]
+	if share := float64(crowns) / float64(blues); math.Abs(share-0.5) > 0.05 {
+		t.Errorf("Expected half of blue pieces to have crowns, got %v.", share)
+	}
+	// Unconditioned regions keep their shared sampler.
+	if len(c.samplers) == 0 {
+		t.Errorf("Expected samplers for unconditioned regions to be kept.")
+	}
+}
+
+func TestMarginals(t *testing.T) {
+	g := NewCollectionGenerator(testConfig(), WithSupply(4), WithOutDir(t.TempDir()), WithSeed(3))
+	if _, err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	want := AttributeWeightMap{
+		{TraitType: "background", Value: "Red"}:  0.5,
+		{TraitType: "background", Value: "Blue"}: 0.5,
+		{TraitType: "hat", Value: "Cap"}:         0.5,
+		{TraitType: "hat", Value: "Crown"}:       0.5,
+		{TraitType: "jewel", Value: "Ruby"}:      0.5,
+	}
+	check := func() {
+		t.Helper()
+		m := g.Marginals()
+		if len(m) != len(want) {
+			t.Errorf("Got marginals %v, expected %v.", m, want)
+		}
+		for attr, w := range want {
+			if m[attr] != w {
+				t.Errorf("%s has a marginal of %v, expected %v.", attr, m[attr], w)
+			}
+		}
+	}
+	check()
+	// One of ones are left out.
+	g.Pieces = append(g.Pieces, &Piece{Id: 5, OneOfOne: &OneOfOne{Name: "Unique", Attributes: []Attribute{{TraitType: "hat", Value: "Unique"}}}})
+	check()
+}
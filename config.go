@@ -1,6 +1,430 @@
 package artwork
 
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// WeightSeparator separates an asset's trait name from its weight in a layer file name, as in "Red Hat#0.25.png".
+	WeightSeparator = "#"
+	// DefaultWeight is the weight given to an asset whose file name does not specify one.
+	DefaultWeight = 1.0
+)
+
+// assetExts is the set of file extensions for which an image decoder is registered.
+var assetExts = map[string]bool{
+	".png":  true,
+	".gif":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
 // Configuration contains configuration data on assets to be used for generating Pieces.
 type Configuration struct {
-	Assets []*Assets
+	Root          *Asset          // The base asset, whose Regions form the top of the composition tree.
+	Bounds        image.Rectangle // The canvas size to use when Root has no image.
+	Assets        []*Asset
+	Rules         []*Rule                         // Constrain which traits may be picked together.
+	Conditions    []*Condition                    // Make traits likelier or less likely, depending on those already picked.
+	OneOfOnes     []*OneOfOne                     // Hand-crafted pieces, to be placed among those generated.
+	Interpolation Interpolation                   // The kernel by which regions resample their assets, unless they choose their own.
+	PixelArt      bool                            // Puts every region in pixel art mode.
+	sources       map[any]Position                // Where assets, regions, rules, conditions and one of ones were declared, if loaded from a file.
+	index         map[*Asset]int                  // Positions of Assets, for encoding DNA.
+	samplers      map[*Region]*AliasTable[*Asset] // Samplers for the unconstrained candidates of each configured region.
+	candidates    map[*Region]int                 // Numbers of candidates of each configured region, for telling when they are unconstrained.
+}
+
+// NewConfiguration creates a new, empty *Configuration.
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		Assets:     make([]*Asset, 0),
+		sources:    make(map[any]Position),
+		samplers:   make(map[*Region]*AliasTable[*Asset]),
+		candidates: make(map[*Region]int),
+	}
+}
+
+// Walk calls f for the root asset, if any, and then for each asset in the configuration.
+func (c *Configuration) Walk(f func(a *Asset)) {
+	if c.Root != nil {
+		f(c.Root)
+	}
+	for _, a := range c.Assets {
+		f(a)
+	}
+}
+
+// Candidates returns the configured assets which may be placed in a region, r; those whose Kind matches one of r.Kinds, in configuration order.
+func (c *Configuration) Candidates(r *Region) []*Asset {
+	candidates := make([]*Asset, 0)
+	for _, a := range c.Assets {
+		for _, k := range r.Kinds {
+			if a.Kind == k {
+				candidates = append(candidates, a)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+// Combinations computes the maximum number of unique pieces the configuration can produce: the number of distinct composition trees which may be built from the root's regions. Regions with no weighted candidates count as always empty. Rules are not taken into account, so where rules are configured, this is an upper bound. Returns an error if the tree is deeper than Build allows.
+func (c *Configuration) Combinations() (*big.Int, error) {
+	if c.Root == nil {
+		return nil, fmt.Errorf("No root asset.")
+	}
+	return c.combinations(c.Root.Regions, make(map[*Asset]*big.Int), quotaPick{}, 0)
+}
+
+// combinations computes the number of distinct branches which may be built from templates, memoizing the count for each asset in memo. The asset of without, if any, is never placed in its region.
+func (c *Configuration) combinations(templates []*Region, memo map[*Asset]*big.Int, without quotaPick, depth int) (*big.Int, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("Composition tree is deeper than %d regions. Do any assets have regions which accept their own kind?", maxDepth)
+	}
+	product := big.NewInt(1)
+	for _, t := range templates {
+		sum := new(big.Int)
+		var weighted bool
+		for _, a := range c.Candidates(t) {
+			if a.Weight <= 0 {
+				continue
+			}
+			weighted = true
+			if t == without.region && a == without.asset {
+				continue
+			}
+			n, ok := memo[a]
+			if !ok {
+				var err error
+				if n, err = c.combinations(a.Regions, memo, without, depth+1); err != nil {
+					return nil, err
+				}
+				memo[a] = n
+			}
+			sum.Add(sum, n)
+		}
+		// A region with no candidates can only be empty.
+		if !weighted {
+			continue
+		}
+		product.Mul(product, sum)
+	}
+	return product, nil
+}
+
+// Weights returns the weights of a set of candidate assets as a WeightMap.
+func Weights(candidates []*Asset) WeightMap[*Asset] {
+	m := make(WeightMap[*Asset], len(candidates))
+	for _, a := range candidates {
+		m[a] = a.Weight
+	}
+	return m
+}
+
+// sampler returns a Sampler for picking one of candidates for a configured region, t, by weight, given the traits already picked. Samplers for a region's full set of candidates are built once, and kept, unless a condition on the picked traits changes their weights.
+func (c *Configuration) sampler(t *Region, picked, candidates []*Asset) Sampler[*Asset] {
+	if weights, conditional := c.conditionalWeights(picked, candidates); conditional {
+		nm, _ := weights.normalized()
+		return newAliasTable(nm)
+	}
+	// Candidates are only ever filtered, so if none are missing, they're the full set.
+	n, ok := c.candidates[t]
+	if !ok {
+		n = len(c.Candidates(t))
+		if c.candidates == nil {
+			c.candidates = make(map[*Region]int)
+		}
+		c.candidates[t] = n
+	}
+	full := len(candidates) == n
+	if s, ok := c.samplers[t]; ok && full {
+		return s
+	}
+	nm, _ := Weights(candidates).normalized()
+	s := newAliasTable(nm)
+	if full {
+		if c.samplers == nil {
+			c.samplers = make(map[*Region]*AliasTable[*Asset])
+		}
+		c.samplers[t] = s
+	}
+	return s
+}
+
+// indexOf returns the position of a, in Assets, or -1 if it is not there.
+func (c *Configuration) indexOf(a *Asset) int {
+	// Rebuild the index if Assets has changed.
+	if len(c.index) != len(c.Assets) || c.index[a] >= len(c.Assets) || c.Assets[c.index[a]] != a {
+		c.index = make(map[*Asset]int, len(c.Assets))
+		for i, ca := range c.Assets {
+			c.index[ca] = i
+		}
+	}
+	if i, ok := c.index[a]; ok {
+		return i
+	}
+	return -1
+}
+
+// errorf creates an error, prefixed with the position where v, an *Asset, *Region, *Rule, *Condition or *OneOfOne, was declared, if known.
+func (c *Configuration) errorf(v any, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if pos, ok := c.sources[v]; ok {
+		return fmt.Errorf("%s: %s", pos, msg)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// Validate checks the configuration for problems which would prevent, or spoil, generation, such as a missing root, or parts of the configuration which are malformed, or refer to traits which do not exist. Returns every problem found as Errors, or nil if there were none.
+func (c *Configuration) Validate() error {
+	var errs Errors
+	if c.Root == nil {
+		errs = append(errs, fmt.Errorf("No root asset."))
+	} else if c.Root.Path == "" && !c.Root.IsLoaded() && c.Bounds.Empty() {
+		errs = append(errs, c.errorf(c.Root, "Root asset has no image, and no size was given."))
+	}
+	// Gather the available kinds.
+	kinds := make(map[string]bool)
+	// Traits of a kind must be named apart, or they could not be told apart in metadata, rules or rarities, and would be ordered at random when picking.
+	named := make(map[TraitRef]bool)
+	for _, a := range c.Assets {
+		kinds[a.Kind] = true
+		ref := TraitRef{a.Kind, a.Name}
+		if named[ref] {
+			errs = append(errs, c.errorf(a, "Asset %q has the same kind, %q, and name as another. Traits of a kind must have different names.", a.Name, a.Kind))
+		}
+		named[ref] = true
+	}
+	c.Walk(func(a *Asset) {
+		if a != c.Root {
+			if a.Kind == "" {
+				errs = append(errs, c.errorf(a, "Asset %q has no kind.", a.Name))
+			}
+			if a.Path == "" && a.Text == nil && !a.IsLoaded() {
+				errs = append(errs, c.errorf(a, "Asset %q has no image path.", a.Name))
+			}
+			if a.Weight < 0 || math.IsNaN(a.Weight) || math.IsInf(a.Weight, 0) {
+				errs = append(errs, c.errorf(a, "Asset %q has an invalid weight, %v. Weights must be finite and non-negative.", a.Name, a.Weight))
+			}
+			if a.Palette != nil {
+				if err := a.Palette.check(); err != nil {
+					errs = append(errs, c.errorf(a, "Asset %q: %s", a.Name, err))
+				}
+			}
+		} else if a.Text != nil {
+			errs = append(errs, c.errorf(a, "Root asset may not be text."))
+		}
+		if a.Text != nil {
+			if err := a.Text.check(); err != nil {
+				errs = append(errs, c.errorf(a, "Asset %q: %s", a.Name, err))
+			}
+		}
+		if a.Path != "" && !a.IsLoaded() {
+			if _, err := os.Stat(a.Path); err != nil {
+				errs = append(errs, c.errorf(a, "Asset %q image is missing: %s", a.Name, err))
+			}
+		}
+		for _, r := range a.Regions {
+			if len(r.Kinds) == 0 {
+				errs = append(errs, c.errorf(r, "Region has no kinds."))
+			}
+			for _, k := range r.Kinds {
+				if !kinds[k] {
+					errs = append(errs, c.errorf(r, "Region kind %q matches no asset.", k))
+				}
+			}
+			errs = append(errs, c.checkRegion(r)...)
+			// Weights are normalized when picking, but warn if they aren't already.
+			if sum := Weights(c.Candidates(r)).Sum(); sum > 0 && !IsNormalized(sum) {
+				logErr.Println(c.errorf(r, "Warning: weights for region kinds %v sum to %v, rather than 1.0. They will be normalized.", r.Kinds, sum))
+			}
+		}
+		for _, part := range a.Parts {
+			if part.Asset == nil || (part.Asset.Path == "" && part.Asset.Text == nil && !part.Asset.IsLoaded()) {
+				errs = append(errs, c.errorf(part, "Asset %q has a part with no image path.", a.Name))
+			} else if part.Asset.Text != nil {
+				if err := part.Asset.Text.check(); err != nil {
+					errs = append(errs, c.errorf(part, "Asset %q part: %s", a.Name, err))
+				}
+			} else if part.Asset.Path != "" && !part.Asset.IsLoaded() {
+				if _, err := os.Stat(part.Asset.Path); err != nil {
+					errs = append(errs, c.errorf(part, "Asset %q part image is missing: %s", a.Name, err))
+				}
+			}
+			errs = append(errs, c.checkRegion(part)...)
+		}
+	})
+	errs = append(errs, c.checkRules()...)
+	errs = append(errs, c.checkConditions()...)
+	errs = append(errs, c.checkOneOfOnes()...)
+	return errs.Err()
+}
+
+// checkRegion checks a region's placement and compositing settings. Returns the problems found.
+func (c *Configuration) checkRegion(r *Region) Errors {
+	var errs Errors
+	if r.Scale != nil && !finite(r.Scale.X, r.Scale.Y) {
+		errs = append(errs, c.errorf(r, "Region has a scale which is not finite, %+v.", *r.Scale))
+	}
+	if t := r.Transform; t != nil {
+		if !finite(t.Rotate, t.SkewX, t.SkewY) || (t.Matrix != nil && !finite(t.Matrix[:]...)) {
+			errs = append(errs, c.errorf(r, "Region has a transform which is not finite."))
+		}
+	}
+	if r.Opacity != nil && !(*r.Opacity >= 0 && *r.Opacity <= 1) {
+		errs = append(errs, c.errorf(r, "Region opacity, %v, is not in [0.0, 1.0].", *r.Opacity))
+	}
+	if r.Anchor != nil && !finite(r.Anchor.X, r.Anchor.Y) {
+		errs = append(errs, c.errorf(r, "Region has an anchor which is not finite, %+v.", *r.Anchor))
+	}
+	if r.PixelArt || c.PixelArt {
+		if err := r.checkPixelArt(); err != nil {
+			errs = append(errs, c.errorf(r, "%s", err))
+		}
+	}
+	if r.Clip != nil && r.Clip.Path != "" && r.Clip.Mask == nil {
+		if _, err := os.Stat(r.Clip.Path); err != nil {
+			errs = append(errs, c.errorf(r, "Region clip mask is missing: %s", err))
+		}
+	}
+	for _, e := range r.Effects {
+		if e == nil {
+			errs = append(errs, c.errorf(r, "Region has a nil effect."))
+			continue
+		}
+		if ch, ok := e.(checker); ok {
+			if err := ch.check(); err != nil {
+				errs = append(errs, c.errorf(r, "%s", err))
+			}
+		}
+	}
+	if _, ok := interpolations[r.Interpolation.String()]; !ok {
+		errs = append(errs, c.errorf(r, "Region has an unknown interpolation, %s.", r.Interpolation))
+	}
+	if _, ok := blendModes[r.Blend.String()]; !ok {
+		errs = append(errs, c.errorf(r, "Region has an unknown blend mode, %s.", r.Blend))
+	}
+	// A singular transform collapses the region's asset to a line, or a point.
+	if m := r.Matrix(); m[0]*m[4]-m[1]*m[3] == 0 {
+		errs = append(errs, c.errorf(r, "Region transform is singular, and would flatten its asset: %v.", m))
+	}
+	return errs
+}
+
+/*
+LoadDir walks a layer directory, root, and returns a *Configuration populated with an Asset for each image found. Layers are expected to be laid out like so:
+
+	<root>/<kind>/<name>#<weight>.<ext>
+
+The directory path relative to root becomes the Asset's Kind, the file name, less its weight and extension, becomes its Name, and the weight becomes its Weight. The weight may be omitted, in which case DefaultWeight is used. Each Asset's image is loaded as it is found.
+
+Every malformed file name and unreadable image is collected, and returned together as Errors, along with whatever could be loaded.
+
+The returned *Configuration has no Root, and so no regions in which to place its assets. Before pieces can be built from it, the caller must set Root to an asset whose Regions take the loaded kinds, and Bounds, if Root has no image.
+*/
+func LoadDir(root string) (*Configuration, error) {
+	c := NewConfiguration()
+	var errs Errors
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to read layer path %q: %s", path, err))
+			// Keep walking, unless root itself is unreadable.
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		// Skip hidden files and directories.
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Skip anything we can't decode.
+		ext := filepath.Ext(d.Name())
+		if !assetExts[strings.ToLower(ext)] {
+			logErr.Printf("Skipping layer file %q: unsupported extension.\n", path)
+			return nil
+		}
+		// The kind is the directory path, relative to root.
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || rel == "." {
+			errs = append(errs, fmt.Errorf("Malformed layer path %q: layer files must be within a kind directory.", path))
+			return nil
+		}
+		name, weight, err := ParseAssetName(strings.TrimSuffix(d.Name(), ext))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Malformed layer file name %q: %s", path, err))
+			return nil
+		}
+		a := NewAsset()
+		a.Kind = filepath.ToSlash(rel)
+		a.Name = name
+		a.Weight = weight
+		a.Path = path
+		if err := a.Load(); err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		c.Assets = append(c.Assets, a)
+		return nil
+	})
+	if err != nil && len(errs) == 0 {
+		errs = append(errs, err)
+	}
+	if err := errs.Err(); err != nil {
+		err := fmt.Errorf("Failed to load layer directory %q:\n%s", root, err)
+		logErr.Println(err)
+		return c, err
+	}
+	return c, nil
+}
+
+// ParseAssetName parses a layer file name, less its extension, of the form "<name>#<weight>". The weight is optional, and defaults to DefaultWeight. Returns the name and weight, or an error if the name is empty or the weight is not a finite, non-negative number.
+func ParseAssetName(s string) (name string, weight float64, err error) {
+	parts := strings.Split(s, WeightSeparator)
+	name = strings.TrimSpace(parts[0])
+	switch {
+	case len(parts) > 2:
+		return "", 0, fmt.Errorf("More than one %q in %q.", WeightSeparator, s)
+	case name == "":
+		return "", 0, fmt.Errorf("Trait name is empty.")
+	case len(parts) == 1:
+		return name, DefaultWeight, nil
+	}
+	weight, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Weight %q is not a number.", parts[1])
+	}
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return "", 0, fmt.Errorf("Weight %q must be a finite, non-negative number.", parts[1])
+	}
+	return name, weight, nil
+}
+
+// finite reports whether every one of vs is neither NaN nor infinite.
+func finite(vs ...float64) bool {
+	for _, v := range vs {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
 }
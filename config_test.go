@@ -0,0 +1,107 @@
+package artwork
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLayer writes a blank PNG image of size w by h to path, under dir, creating its directories.
+func writeLayer(t *testing.T, dir, path string, w, h int) {
+	t.Helper()
+	path = filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAssetName(t *testing.T) {
+	for _, test := range []struct {
+		s      string
+		name   string
+		weight float64
+		err    bool
+	}{
+		{"Red Hat", "Red Hat", DefaultWeight, false},
+		{"Red Hat#0.25", "Red Hat", 0.25, false},
+		{" Red Hat # 2 ", "Red Hat", 2, false},
+		{"Red Hat#0", "Red Hat", 0, false},
+		{"#0.25", "", 0, true},
+		{"Red Hat#heavy", "", 0, true},
+		{"Red Hat#-1", "", 0, true},
+		{"Red Hat#Inf", "", 0, true},
+		{"Red#Hat#1", "", 0, true},
+	} {
+		name, weight, err := ParseAssetName(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("ParseAssetName(%q) returned error %v, expected an error: %v.", test.s, err, test.err)
+			continue
+		}
+		if name != test.name || weight != test.weight {
+			t.Errorf("ParseAssetName(%q) = %q, %v, expected %q, %v.", test.s, name, weight, test.name, test.weight)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeLayer(t, dir, "background/Red#2.png", 4, 4)
+	writeLayer(t, dir, "background/Blue.png", 4, 4)
+	writeLayer(t, dir, "head/hat/Crown#0.5.png", 2, 2)
+	writeLayer(t, dir, ".hidden/Secret.png", 2, 2)
+	writeLayer(t, dir, "background/.Ignored.png", 2, 2)
+	if err := os.WriteFile(filepath.Join(dir, "background", "notes.txt"), []byte("Not an image."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, a := range c.Assets {
+		if !a.IsLoaded() {
+			t.Errorf("Asset %s was not loaded.", a.Kind+"/"+a.Name)
+		}
+		got[a.Kind+"/"+a.Name] = a.Weight
+	}
+	want := map[string]float64{"background/Red": 2, "background/Blue": DefaultWeight, "head/hat/Crown": 0.5}
+	if len(got) != len(want) {
+		t.Errorf("Loaded %v, expected %v.", got, want)
+	}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("Asset %s has weight %v, expected %v.", k, got[k], w)
+		}
+	}
+
+	// Every problem is reported together, along with whatever could be loaded.
+	writeLayer(t, dir, "Loose.png", 2, 2)
+	writeLayer(t, dir, "background/#3.png", 2, 2)
+	writeLayer(t, dir, "background/Green#x.png", 2, 2)
+	if err := os.WriteFile(filepath.Join(dir, "background", "Broken.png"), []byte("Not a PNG."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadDir(dir)
+	if err == nil {
+		t.Fatal("Expected errors, got none.")
+	}
+	for _, want := range []string{"Loose.png", "#3.png", "Green#x.png", "Broken.png"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error about %q, got:\n%s", want, err)
+		}
+	}
+	if len(c.Assets) != 3 {
+		t.Errorf("Expected the 3 good assets to be loaded anyway, got %d.", len(c.Assets))
+	}
+}
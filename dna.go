@@ -0,0 +1,72 @@
+package artwork
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// geneSeparator separates genes in encoded DNA.
+	geneSeparator = "."
+	// emptyGene is the gene recorded for a region left empty.
+	emptyGene = -1
+	// emptyGeneCode is the encoding of emptyGene.
+	emptyGeneCode = "_"
+)
+
+/*
+EncodeDNA encodes a piece's genes as a compact DNA string. Each gene is the position, in Configuration.Assets, of the asset picked for a region, in the order the composition tree was built, depth first. Genes are encoded in base 36, and regions left empty as "_". For example:
+
+	[]int{3, 40, -1, 0} encodes as "3.14._.0"
+*/
+func EncodeDNA(genes []int) string {
+	codes := make([]string, len(genes))
+	for i, g := range genes {
+		if g == emptyGene {
+			codes[i] = emptyGeneCode
+			continue
+		}
+		codes[i] = strconv.FormatInt(int64(g), 36)
+	}
+	return strings.Join(codes, geneSeparator)
+}
+
+// DecodeDNA decodes a DNA string, as produced by EncodeDNA, into genes. Returns the genes, or an error if dna is malformed.
+func DecodeDNA(dna string) ([]int, error) {
+	if dna == "" {
+		return []int{}, nil
+	}
+	codes := strings.Split(dna, geneSeparator)
+	genes := make([]int, len(codes))
+	for i, code := range codes {
+		if code == emptyGeneCode {
+			genes[i] = emptyGene
+			continue
+		}
+		g, err := strconv.ParseInt(code, 36, 0)
+		if err != nil || g < 0 {
+			return nil, fmt.Errorf("Malformed DNA %q: gene %d, %q, is invalid.", dna, i, code)
+		}
+		genes[i] = int(g)
+	}
+	return genes, nil
+}
+
+// DNAHash returns the hex encoded SHA-256 hash of a DNA string.
+func DNAHash(dna string) string {
+	sum := sha256.Sum256([]byte(dna))
+	return hex.EncodeToString(sum[:])
+}
+
+// PieceSeed derives the random seed for a piece, id, from a collection's seed, so that each piece's picks depend only on the collection seed and its own id.
+func PieceSeed(seed int64, id uint) int64 {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(seed))
+	binary.BigEndian.PutUint64(b[8:], uint64(id))
+	sum := sha256.Sum256(b[:])
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
@@ -0,0 +1,121 @@
+package artwork
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image/png"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/Jsewill/chia/nft"
+)
+
+func TestDNA(t *testing.T) {
+	for _, test := range []struct {
+		genes []int
+		dna   string
+	}{
+		{[]int{}, ""},
+		{[]int{3, 40, -1, 0}, "3.14._.0"},
+		{[]int{-1}, "_"},
+		{[]int{36 * 36}, "100"},
+	} {
+		if dna := EncodeDNA(test.genes); dna != test.dna {
+			t.Errorf("EncodeDNA(%v) = %q, expected %q.", test.genes, dna, test.dna)
+		}
+		genes, err := DecodeDNA(test.dna)
+		if err != nil {
+			t.Errorf("DecodeDNA(%q) failed: %s", test.dna, err)
+		} else if !reflect.DeepEqual(genes, test.genes) {
+			t.Errorf("DecodeDNA(%q) = %v, expected %v.", test.dna, genes, test.genes)
+		}
+	}
+	for _, dna := range []string{"3..0", "3.!", "-1", "3._x"} {
+		if _, err := DecodeDNA(dna); err == nil {
+			t.Errorf("Expected malformed DNA %q to fail to decode.", dna)
+		}
+	}
+}
+
+func TestPieceSeed(t *testing.T) {
+	seeds := make(map[int64]bool)
+	for id := uint(0); id < 100; id++ {
+		s := PieceSeed(42, id)
+		if s != PieceSeed(42, id) {
+			t.Fatalf("Piece #%d's seed differs for the same collection seed.", id)
+		}
+		seeds[s] = true
+	}
+	if len(seeds) != 100 {
+		t.Errorf("Expected 100 distinct piece seeds, got %d.", len(seeds))
+	}
+	if PieceSeed(42, 1) == PieceSeed(43, 1) {
+		t.Errorf("Expected a piece's seed to depend on the collection seed.")
+	}
+}
+
+func TestBuildDNA(t *testing.T) {
+	c := testConfig()
+	for seed := int64(0); seed < 20; seed++ {
+		p := NewPiece(1, nil, nil)
+		if err := p.Build(c, rand.New(rand.NewSource(seed))); err != nil {
+			t.Fatal(err)
+		}
+		q := NewPiece(1, nil, nil)
+		if err := q.BuildDNA(c, p.DNA); err != nil {
+			t.Fatal(err)
+		}
+		if q.DNA != p.DNA || !reflect.DeepEqual(traitNames(q), traitNames(p)) {
+			t.Errorf("Rebuilt DNA %q as %q, with traits %v, expected %v.", p.DNA, q.DNA, traitNames(q), traitNames(p))
+		}
+	}
+	for _, dna := range []string{
+		"x",     // Malformed.
+		"0",     // Too short.
+		"0.2.0", // Too long; the cap has no regions.
+		"2.2",   // A hat in the background.
+		"0.9",   // No such asset.
+	} {
+		if err := NewPiece(1, nil, nil).BuildDNA(c, dna); err == nil {
+			t.Errorf("Expected DNA %q to fail to build.", dna)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	generate := func(seed int64) (*CollectionGenerator, nft.Collection) {
+		g := NewCollectionGenerator(testConfig(), WithSupply(4), WithOutDir(t.TempDir()), WithSeed(seed))
+		c, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g, c
+	}
+	a, ac := generate(7)
+	b, bc := generate(7)
+	for i := range a.Pieces {
+		if ac.NFTs[i].Asset.Hash != bc.NFTs[i].Asset.Hash {
+			t.Errorf("Piece #%d has a different image with the same seed.", a.Pieces[i].Id)
+		}
+		if a.Pieces[i].DNA != b.Pieces[i].DNA {
+			t.Errorf("Piece #%d has DNA %q, then %q, with the same seed.", a.Pieces[i].Id, a.Pieces[i].DNA, b.Pieces[i].DNA)
+		}
+	}
+	// A piece rendered from its DNA is the piece generated.
+	p, err := a.Render(1, a.Pieces[0].DNA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, p.Image); err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(buf.Bytes()); hex.EncodeToString(sum[:]) != ac.NFTs[0].Asset.Hash {
+		t.Errorf("Rendering piece #1 from its DNA gave a different image.")
+	}
+	if _, err := a.Render(1, "2.2"); err == nil {
+		t.Errorf("Expected rendering from DNA which does not fit to fail.")
+	}
+}
@@ -0,0 +1,211 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Effect is a layer effect, applied to the composite of a region's branch before it is drawn onto its parent. Apply returns the composite, src, with the effect applied, in the same coordinates, grown to fit whatever the effect adds.
+type Effect interface {
+	Apply(src image.Image) *image.NRGBA
+}
+
+// checker is implemented by effects which can check their own settings. Check returns an error describing the first problem found, or nil.
+type checker interface {
+	check() error
+}
+
+// DropShadow draws a shadow of the layer beneath it, from its alpha channel: shifted by Offset, blurred by a radius, Blur, in pixels, and filled with Color, whose alpha sets the shadow's opacity.
+type DropShadow struct {
+	Offset image.Point
+	Blur   float64
+	Color  color.NRGBA
+}
+
+// Apply applies the drop shadow to src.
+func (e *DropShadow) Apply(src image.Image) *image.NRGBA {
+	margin := int(math.Ceil(3 * e.Blur))
+	sb := src.Bounds()
+	bounds := sb.Union(sb.Add(e.Offset).Inset(-margin))
+	shadow := newAlphaMask(src, bounds.Sub(e.Offset)).blur(e.Blur)
+	shadow.bounds = shadow.bounds.Add(e.Offset)
+	return shadow.under(src, e.Color)
+}
+
+func (e *DropShadow) check() error {
+	if !finite(e.Blur) || e.Blur < 0 {
+		return fmt.Errorf("Drop shadow blur, %v, must be finite and non-negative.", e.Blur)
+	}
+	return nil
+}
+
+// Stroke outlines the layer with a stroke of Width pixels, around its alpha channel, filled with Color.
+type Stroke struct {
+	Width float64
+	Color color.NRGBA
+}
+
+// Apply applies the stroke to src.
+func (e *Stroke) Apply(src image.Image) *image.NRGBA {
+	bounds := src.Bounds().Inset(-int(math.Ceil(e.Width)))
+	return newAlphaMask(src, bounds).dilate(e.Width).under(src, e.Color)
+}
+
+func (e *Stroke) check() error {
+	if !finite(e.Width) || e.Width <= 0 {
+		return fmt.Errorf("Stroke width, %v, must be finite and positive.", e.Width)
+	}
+	return nil
+}
+
+// OuterGlow surrounds the layer with a soft glow, from its alpha channel: spread by Spread pixels, blurred by a radius, Blur, and filled with Color, whose alpha sets the glow's opacity.
+type OuterGlow struct {
+	Spread float64
+	Blur   float64
+	Color  color.NRGBA
+}
+
+// Apply applies the outer glow to src.
+func (e *OuterGlow) Apply(src image.Image) *image.NRGBA {
+	bounds := src.Bounds().Inset(-int(math.Ceil(e.Spread + 3*e.Blur)))
+	return newAlphaMask(src, bounds).dilate(e.Spread).blur(e.Blur).under(src, e.Color)
+}
+
+func (e *OuterGlow) check() error {
+	if !finite(e.Spread, e.Blur) || e.Spread < 0 || e.Blur < 0 {
+		return fmt.Errorf("Outer glow spread, %v, and blur, %v, must be finite and non-negative.", e.Spread, e.Blur)
+	}
+	return nil
+}
+
+// applyEffects applies the region's effects to its composite, comp, in order, each to the result of the last.
+func (r *Region) applyEffects(comp image.Image) image.Image {
+	for _, e := range r.Effects {
+		comp = e.Apply(comp)
+	}
+	return comp
+}
+
+// alphaMask is the coverage of an image, from its alpha channel, in [0.0, 1.0], over bounds.
+type alphaMask struct {
+	bounds image.Rectangle
+	a      []float64
+}
+
+// newAlphaMask returns the alpha mask of img, over bounds. Anywhere outside img is uncovered.
+func newAlphaMask(img image.Image, bounds image.Rectangle) *alphaMask {
+	m := &alphaMask{bounds: bounds, a: make([]float64, bounds.Dx()*bounds.Dy())}
+	ib := img.Bounds().Intersect(bounds)
+	for y := ib.Min.Y; y < ib.Max.Y; y++ {
+		for x := ib.Min.X; x < ib.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			m.a[m.offset(x, y)] = float64(a) / 0xffff
+		}
+	}
+	return m
+}
+
+// offset returns the index of x, y in the mask.
+func (m *alphaMask) offset(x, y int) int {
+	return (y-m.bounds.Min.Y)*m.bounds.Dx() + x - m.bounds.Min.X
+}
+
+// dilate grows the mask's coverage by a radius, r, in pixels, taking the greatest coverage within the radius of each pixel. Returns the dilated mask.
+func (m *alphaMask) dilate(r float64) *alphaMask {
+	if r <= 0 {
+		return m
+	}
+	// The offsets of every pixel within the radius.
+	n := int(math.Ceil(r))
+	disk := make([]image.Point, 0)
+	for dy := -n; dy <= n; dy++ {
+		for dx := -n; dx <= n; dx++ {
+			if float64(dx*dx+dy*dy) <= r*r {
+				disk = append(disk, image.Point{dx, dy})
+			}
+		}
+	}
+	d := &alphaMask{bounds: m.bounds, a: make([]float64, len(m.a))}
+	w, h := m.bounds.Dx(), m.bounds.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var max float64
+			for _, p := range disk {
+				sx, sy := x+p.X, y+p.Y
+				if sx < 0 || sy < 0 || sx >= w || sy >= h {
+					continue
+				}
+				if a := m.a[sy*w+sx]; a > max {
+					max = a
+					if max == 1 {
+						break
+					}
+				}
+			}
+			d.a[y*w+x] = max
+		}
+	}
+	return d
+}
+
+// blur blurs the mask with a Gaussian blur of a radius, r, in pixels, taken as twice its standard deviation. Returns the blurred mask.
+func (m *alphaMask) blur(r float64) *alphaMask {
+	if r <= 0 {
+		return m
+	}
+	sigma := r / 2
+	n := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*n+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - n)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	w, h := m.bounds.Dx(), m.bounds.Dy()
+	// Blur rows, then columns.
+	pass := func(src []float64, step, length, lines, stride int) []float64 {
+		dst := make([]float64, len(src))
+		for l := 0; l < lines; l++ {
+			base := l * stride
+			for i := 0; i < length; i++ {
+				var v float64
+				for k, weight := range kernel {
+					j := i + k - n
+					if j < 0 || j >= length {
+						continue
+					}
+					v += weight * src[base+j*step]
+				}
+				dst[base+i*step] = v
+			}
+		}
+		return dst
+	}
+	a := pass(m.a, 1, w, h, w)
+	a = pass(a, w, h, w, 1)
+	return &alphaMask{bounds: m.bounds, a: a}
+}
+
+// under fills the mask with a color, c, scaling its alpha by the mask's coverage, and draws src over it. Returns the result, with the bounds of the mask and src, combined.
+func (m *alphaMask) under(src image.Image, c color.NRGBA) *image.NRGBA {
+	bounds := m.bounds.Union(src.Bounds())
+	dst := image.NewNRGBA(bounds)
+	for y := m.bounds.Min.Y; y < m.bounds.Max.Y; y++ {
+		for x := m.bounds.Min.X; x < m.bounds.Max.X; x++ {
+			a := m.a[m.offset(x, y)] * float64(c.A)
+			if a <= 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{c.R, c.G, c.B, uint8(math.Round(math.Min(a, 0xff)))})
+		}
+	}
+	draw.Draw(dst, src.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,54 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEffects(t *testing.T) {
+	// A 4x4 white square.
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+		}
+	}
+	black := color.NRGBA{0, 0, 0, 255}
+
+	stroked := (&Stroke{Width: 1, Color: black}).Apply(src)
+	if b := stroked.Bounds(); b != image.Rect(-1, -1, 5, 5) {
+		t.Errorf("Stroked bounds are %v, expected %v.", b, image.Rect(-1, -1, 5, 5))
+	}
+	for _, test := range []struct {
+		p    image.Point
+		want color.NRGBA
+	}{
+		{image.Pt(1, 1), color.NRGBA{255, 255, 255, 255}},
+		{image.Pt(-1, 1), black},
+		{image.Pt(1, 4), black},
+		// Corners are beyond the stroke's radius.
+		{image.Pt(-1, -1), color.NRGBA{}},
+	} {
+		if got := stroked.NRGBAAt(test.p.X, test.p.Y); got != test.want {
+			t.Errorf("Stroked pixel at %v is %v, expected %v.", test.p, got, test.want)
+		}
+	}
+
+	shadowed := (&DropShadow{Offset: image.Pt(2, 2), Color: black}).Apply(src)
+	if b := shadowed.Bounds(); b != image.Rect(0, 0, 6, 6) {
+		t.Errorf("Shadowed bounds are %v, expected %v.", b, image.Rect(0, 0, 6, 6))
+	}
+	if got := shadowed.NRGBAAt(5, 5); got != black {
+		t.Errorf("Shadow at 5, 5 is %v, expected %v.", got, black)
+	}
+	if got := shadowed.NRGBAAt(3, 3); got.R != 255 {
+		t.Errorf("Shadow should lie beneath the layer, but 3, 3 is %v.", got)
+	}
+
+	glowed := (&OuterGlow{Blur: 2, Color: color.NRGBA{255, 255, 0, 255}}).Apply(src)
+	near, far := glowed.NRGBAAt(-1, 2), glowed.NRGBAAt(-3, 2)
+	if near.A == 0 || far.A >= near.A {
+		t.Errorf("Glow should fade with distance, but is %v at 1 pixel, and %v at 3.", near, far)
+	}
+}
@@ -0,0 +1,23 @@
+package artwork
+
+import "strings"
+
+// Errors is a list of errors which is itself an error. It is used where it is more helpful to report every failure at once, rather than stopping at the first.
+type Errors []error
+
+// Error implements the error interface, joining each error's message on its own line.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns the receiver as an error if it contains any errors, otherwise it returns nil.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
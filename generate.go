@@ -3,6 +3,10 @@ package artwork
 import (
 	"image"
 	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
 )
 
 /* @TODO: Find a replacement for NFTStorage. Make an interface for image storage service APIs.
@@ -19,16 +23,16 @@ func Upload() {
 	nft := nftstorage.NewNFT()
 }*/
 
-// GrowImage enlarges an image to match the supplied rectangle bounds. Returns the grown image.Image.
-func GrowImage(orig image.Image, bounds image.Rectangle) image.Image {
+// GrowImage enlarges an image to include the supplied rectangle bounds. Returns the grown draw.Image, or orig if it already contains bounds.
+func GrowImage(orig draw.Image, bounds image.Rectangle) draw.Image {
 	// Only grow image if needed.
-	if !cbounds.In(canvas.Bounds()) {
+	if !bounds.In(orig.Bounds()) {
 		// Get the union of our branch composite and the current canvas.
 		union := bounds.Union(orig.Bounds())
 		// Create a canvas to replace the old one.
 		grown := image.NewNRGBA(union)
-		// Draw the asset onto the canvas.
-		draw.Over.Draw(grown, union, orig, union.Min)
+		// Draw the original onto the canvas, where it was.
+		draw.Draw(grown, orig.Bounds(), orig, orig.Bounds().Min, draw.Src)
 		return grown
 	}
 	return orig
@@ -40,7 +44,7 @@ type Scale struct {
 
 // ScaleRectangle scales a rectangle, orig, base on a scale factor, s. returns an image.Rectangle.
 func ScaleRectangle(sx, sy float64, orig image.Rectangle) image.Rectangle {
-	// Only scale if s is non-zero, non-negative. @TODO: Consider implementing negative scaling for image inversion.
+	// Only scale if s is non-zero, non-negative. Negative scales mirror, and are handled by TransformImage.
 	if sx == 0 && sy == 0 {
 		return orig
 	}
@@ -56,19 +60,50 @@ func ScaleRectangle(sx, sy float64, orig image.Rectangle) image.Rectangle {
 	pscale := image.Point{int(float64(orig.Dx()) * xFactor), int(float64(orig.Dy()) * yFactor)}
 	// Translate orig to Zero.
 	var omin image.Point
-	if orig.Min != image.Point {
-		omin := orig.Min
+	if orig.Min != (image.Point{}) {
+		omin = orig.Min
 		orig = orig.Sub(omin)
 	}
 	// Add the scale factor to shrink or grow the rectangle.
-	orig := image.Rectangle{image.Point{}, orig.Max.Add(pscale)}
+	orig = image.Rectangle{image.Point{}, orig.Max.Add(pscale)}
 	// Put orig back where it was.
-	if omin != image.Point {
+	if omin != (image.Point{}) {
 		orig = orig.Add(omin)
 	}
 	return orig
 }
 
+/*
+TransformImage applies the linear part of an affine transform, m, to an image, src, about its center, resampling it with an Interpolator, interp, or ApproxBiLinear, if nil. The result is given bounds large enough to hold the whole of the transformed image, centered on the origin, so that nothing is clipped. Returns the transformed *image.NRGBA.
+*/
+func TransformImage(src image.Image, m f64.Aff3, interp xdraw.Interpolator) *image.NRGBA {
+	sb := src.Bounds()
+	cx, cy := float64(sb.Min.X+sb.Max.X)/2, float64(sb.Min.Y+sb.Max.Y)/2
+	// Transform the corners, about the center, to find the bounds of the result.
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{sb.Min, {sb.Max.X, sb.Min.Y}, sb.Max, {sb.Min.X, sb.Max.Y}} {
+		dx, dy := float64(p.X)-cx, float64(p.Y)-cy
+		x, y := m[0]*dx+m[1]*dy, m[3]*dx+m[4]*dy
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	// Allow for rounding error, so that right angles don't grow the bounds by a pixel.
+	const epsilon = 1e-9
+	db := image.Rect(int(math.Floor(minX+epsilon)), int(math.Floor(minY+epsilon)), int(math.Ceil(maxX-epsilon)), int(math.Ceil(maxY-epsilon)))
+	dst := image.NewNRGBA(db)
+	// Map the source's center to the origin.
+	s2d := f64.Aff3{
+		m[0], m[1], -(m[0]*cx + m[1]*cy),
+		m[3], m[4], -(m[3]*cx + m[4]*cy),
+	}
+	if interp == nil {
+		interp = xdraw.ApproxBiLinear
+	}
+	interp.Transform(dst, s2d, src, sb, draw.Src, nil)
+	return dst
+}
+
 // CenterOffset gets the offset required to center a rectangle, bounds, on a point, center
 func CenterOffset(center image.Point, bounds image.Rectangle) image.Point {
 	return center.Sub(bounds.Max.Sub(bounds.Min).Div(2))
@@ -2,21 +2,44 @@ package artwork
 
 import (
 	"image"
+	"image/color"
+	"image/draw"
 	_ "image/jpeg"
 	"testing"
 )
 
 func TestComposite(t *testing.T) {
-	// t.Fatal("not implemented")
-	p := NewPiece(1, image.NewNRGBA(image.Pt(500, 500)), &image.Rectangle{1000, 1000})
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	canvas := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	p := NewPiece(1, canvas, nil)
 
 	r := NewRegion()
+	r.Coords = &image.Point{5, 5}
 	a := NewAsset()
-	//a.Path = ""
-	a.Load()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+	a.Image = img
 	a.Parent = r
 	r.Asset = a
 	p.Asset.Regions = append(p.Asset.Regions, r)
 
-	p.Composite()
+	if err := p.Composite(); err != nil {
+		t.Fatal(err)
+	}
+	if b := p.Asset.Image.Bounds(); b != canvas.Bounds() {
+		t.Fatalf("Composited bounds are %v, expected %v.", b, canvas.Bounds())
+	}
+	// The asset is centered on the region's coordinates.
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			want := red
+			if x >= 3 && x < 7 && y >= 3 && y < 7 {
+				want = blue
+			}
+			if c := color.NRGBAModel.Convert(p.Asset.Image.At(x, y)); c != want {
+				t.Fatalf("Pixel %d, %d is %v, expected %v.", x, y, c, want)
+			}
+		}
+	}
 }
@@ -5,4 +5,7 @@ go 1.18
 require (
 	github.com/Jsewill/chia v0.0.0-20220804071401-1c359c3c9037
 	golang.org/x/image v0.0.0-20220722155232-062f8c9fd539
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/text v0.3.7 // indirect
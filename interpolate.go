@@ -0,0 +1,109 @@
+package artwork
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Interpolation is a kernel by which images are resampled when scaled or transformed.
+type Interpolation int
+
+const (
+	// InterpolateDefault leaves the choice to the configuration, for a region, or else ApproxBiLinear.
+	InterpolateDefault Interpolation = iota
+	// InterpolateApproxBiLinear is a fast approximation of bilinear interpolation.
+	InterpolateApproxBiLinear
+	// InterpolateNearestNeighbor takes the nearest pixel, keeping hard edges, as pixel art needs.
+	InterpolateNearestNeighbor
+	// InterpolateBiLinear blends the four nearest pixels.
+	InterpolateBiLinear
+	// InterpolateCatmullRom blends the sixteen nearest pixels with a Catmull-Rom spline, for the sharpest smooth results, at the greatest cost.
+	InterpolateCatmullRom
+)
+
+// interpolations maps the name of each interpolation to the interpolation.
+var interpolations = map[string]Interpolation{
+	"default":          InterpolateDefault,
+	"approx-bilinear":  InterpolateApproxBiLinear,
+	"nearest-neighbor": InterpolateNearestNeighbor,
+	"bilinear":         InterpolateBiLinear,
+	"catmull-rom":      InterpolateCatmullRom,
+}
+
+// String returns the name of the interpolation.
+func (i Interpolation) String() string {
+	for name, in := range interpolations {
+		if in == i {
+			return name
+		}
+	}
+	return fmt.Sprintf("Interpolation(%d)", int(i))
+}
+
+// ParseInterpolation returns the interpolation with the given name, as returned by Interpolation.String. Returns an error if there is no such interpolation.
+func ParseInterpolation(name string) (Interpolation, error) {
+	if i, ok := interpolations[strings.ToLower(name)]; ok {
+		return i, nil
+	}
+	names := make([]string, 0, len(interpolations))
+	for n := range interpolations {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("Unknown interpolation %q. Expected one of: %s.", name, strings.Join(names, ", "))
+}
+
+// Interpolator returns the x/image/draw Interpolator for the interpolation. InterpolateDefault gives ApproxBiLinear.
+func (i Interpolation) Interpolator() xdraw.Interpolator {
+	switch i {
+	case InterpolateNearestNeighbor:
+		return xdraw.NearestNeighbor
+	case InterpolateBiLinear:
+		return xdraw.BiLinear
+	case InterpolateCatmullRom:
+		return xdraw.CatmullRom
+	}
+	return xdraw.ApproxBiLinear
+}
+
+// interpolator returns the Interpolator with which the region's asset is scaled and transformed: nearest neighbor, in pixel art mode, or else that of the region's Interpolation.
+func (r *Region) interpolator() xdraw.Interpolator {
+	if r.PixelArt {
+		return xdraw.NearestNeighbor
+	}
+	return r.Interpolation.Interpolator()
+}
+
+// checkPixelArt checks that a region in pixel art mode keeps its asset's pixels whole and square: scale factors must be whole numbers, rotations quarter turns, and matrices whole, with nothing skewed, and no other interpolation chosen. Returns an error describing the first problem found, or nil.
+func (r *Region) checkPixelArt() error {
+	whole := func(vs ...float64) bool {
+		for _, v := range vs {
+			if v != math.Trunc(v) {
+				return false
+			}
+		}
+		return true
+	}
+	if r.Scale != nil && !whole(r.Scale.X, r.Scale.Y) {
+		return fmt.Errorf("Region is in pixel art mode, but its scale, %+v, is not whole.", *r.Scale)
+	}
+	if t := r.Transform; t != nil {
+		if !whole(t.Rotate / 90) {
+			return fmt.Errorf("Region is in pixel art mode, but its rotation, %v, is not a quarter turn.", t.Rotate)
+		}
+		if t.SkewX != 0 || t.SkewY != 0 {
+			return fmt.Errorf("Region is in pixel art mode, but is skewed.")
+		}
+		if t.Matrix != nil && !whole(t.Matrix[0], t.Matrix[1], t.Matrix[3], t.Matrix[4]) {
+			return fmt.Errorf("Region is in pixel art mode, but its matrix, %v, is not whole.", *t.Matrix)
+		}
+	}
+	if r.Interpolation != InterpolateDefault && r.Interpolation != InterpolateNearestNeighbor {
+		return fmt.Errorf("Region is in pixel art mode, but is set to %s interpolation.", r.Interpolation)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPixelArtScale(t *testing.T) {
+	// A 2x2 checkerboard, scaled up 16 times, should stay a crisp checkerboard.
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.NRGBA{255, 255, 255, 255})
+	src.Set(1, 1, color.NRGBA{255, 255, 255, 255})
+	region := &Region{Scale: &Scale{16, 16}, PixelArt: true, Interpolation: InterpolateBiLinear}
+	if err := region.checkPixelArt(); err == nil {
+		t.Errorf("Pixel art with bilinear interpolation should not pass.")
+	}
+	region.Interpolation = InterpolateDefault
+	if err := region.checkPixelArt(); err != nil {
+		t.Fatal(err)
+	}
+	region.Asset = &Asset{Image: src, Parent: region}
+	comp, err := region.Composite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := comp.Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+		t.Fatalf("Scaled bounds are %v, expected 32x32.", b)
+	}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			want := uint32(0)
+			if (x < 16) == (y < 16) {
+				want = 0xffff
+			}
+			if r, _, _, _ := comp.At(x, y).RGBA(); r != want {
+				t.Fatalf("Pixel %d, %d is %v, expected %v.", x, y, r, want)
+			}
+		}
+	}
+}
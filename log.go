@@ -11,10 +11,6 @@ var (
 )
 
 func init() {
-	var (
-		logErr = log.Default
-		logger = log.New(os.StdOut, logPrefix, log.LstdFlags)
-	)
-	_ = logger
+	logErr = log.Default()
 	logErr.SetPrefix(logPrefix)
 }
@@ -0,0 +1,102 @@
+package artwork
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// OneOfOne is a hand-crafted, one of a kind piece, made outside of generation, to be inserted into a generated collection. Path is its image file, which is written to the collection as it is. Name and Description replace those a generated piece would be given in its metadata, and Attributes its traits. Data is added to its metadata's data. Position is the piece's id within the collection, or 0 to place it at random.
+type OneOfOne struct {
+	Name        string
+	Description string
+	Path        string
+	Attributes  []Attribute
+	Data        map[string]any
+	Position    uint
+}
+
+// checkOneOfOnes checks the configured one of ones: each must have an image which exists, and no two may share a position.
+func (c *Configuration) checkOneOfOnes() Errors {
+	var errs Errors
+	positions := make(map[uint]*OneOfOne)
+	for _, o := range c.OneOfOnes {
+		if o.Path == "" {
+			errs = append(errs, c.errorf(o, "One of one %q has no image path.", o.Name))
+		} else if _, err := os.Stat(o.Path); err != nil {
+			errs = append(errs, c.errorf(o, "One of one %q image is missing: %s", o.Name, err))
+		}
+		if o.Position == 0 {
+			continue
+		}
+		if other, ok := positions[o.Position]; ok {
+			errs = append(errs, c.errorf(o, "One of one %q has the same position, %d, as %q.", o.Name, o.Position, other.Name))
+		}
+		positions[o.Position] = o
+	}
+	return errs
+}
+
+/*
+placeOneOfOnes assigns an id to each configured one of one: its own Position, if it has one, or else one chosen at random, from those left, with the collection's seed. Returns a *Piece for each one of one, keyed by id, and the ids left for generated pieces, in order; or an error if the one of ones do not fit the supply.
+*/
+func (g *CollectionGenerator) placeOneOfOnes() (map[uint]*Piece, []uint, error) {
+	if uint(len(g.Config.OneOfOnes)) > g.Supply {
+		return nil, nil, fmt.Errorf("%d one of ones are configured, but the supply is only %d.", len(g.Config.OneOfOnes), g.Supply)
+	}
+	placed := make(map[uint]*Piece, len(g.Config.OneOfOnes))
+	random := make([]*OneOfOne, 0)
+	for _, o := range g.Config.OneOfOnes {
+		if o.Position == 0 {
+			random = append(random, o)
+			continue
+		}
+		if o.Position > g.Supply {
+			return nil, nil, fmt.Errorf("One of one %q has position %d, beyond the supply of %d.", o.Name, o.Position, g.Supply)
+		}
+		placed[o.Position] = newOneOfOnePiece(o.Position, o)
+	}
+	free := make([]uint, 0, g.Supply)
+	for id := uint(1); id <= g.Supply; id++ {
+		if _, ok := placed[id]; !ok {
+			free = append(free, id)
+		}
+	}
+	// No piece has id 0, so its seed is free for placing one of ones.
+	rnd := rand.New(rand.NewSource(PieceSeed(g.Seed, 0)))
+	rnd.Shuffle(len(free), func(i, j int) {
+		free[i], free[j] = free[j], free[i]
+	})
+	for i, o := range random {
+		placed[free[i]] = newOneOfOnePiece(free[i], o)
+	}
+	// Whatever is left is for generated pieces.
+	ids := free[len(random):]
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return placed, ids, nil
+}
+
+// newOneOfOnePiece creates a *Piece, id, for a one of one, o.
+func newOneOfOnePiece(id uint, o *OneOfOne) *Piece {
+	return &Piece{Id: id, Asset: &Asset{Path: o.Path}, OneOfOne: o}
+}
+
+// writeOneOfOne copies a one of one piece's image into the output directory, keeping its file extension. Returns the path and hex encoded SHA-256 hash of the copy.
+func (g *CollectionGenerator) writeOneOfOne(p *Piece) (string, string, error) {
+	src, err := os.Open(p.OneOfOne.Path)
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+	path := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, p.Id)) + filepath.Ext(p.OneOfOne.Path)
+	hash, err := writeHashed(path, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	return path, hash, err
+}
@@ -0,0 +1,122 @@
+package artwork
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPlaceOneOfOnes(t *testing.T) {
+	place := func(seed int64) (map[uint]*Piece, []uint) {
+		c := testConfig()
+		c.OneOfOnes = []*OneOfOne{{Name: "Fixed", Position: 2}, {Name: "First"}, {Name: "Second"}}
+		g := NewCollectionGenerator(c, WithSupply(5), WithSeed(seed))
+		placed, ids, err := g.placeOneOfOnes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return placed, ids
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		placed, ids := place(seed)
+		if p := placed[2]; p == nil || p.OneOfOne.Name != "Fixed" {
+			t.Errorf("With seed %d, expected Fixed at its position, 2, got %v.", seed, p)
+		}
+		if len(placed) != 3 || len(ids) != 2 || !sort.SliceIsSorted(ids, func(i, j int) bool { return ids[i] < ids[j] }) {
+			t.Fatalf("With seed %d, placed %d one of ones, leaving ids %v.", seed, len(placed), ids)
+		}
+		all := append([]uint{}, ids...)
+		for id, p := range placed {
+			if p.Id != id {
+				t.Errorf("With seed %d, one of one %q has id %d, but was placed at %d.", seed, p.OneOfOne.Name, p.Id, id)
+			}
+			all = append(all, id)
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+		if !reflect.DeepEqual(all, []uint{1, 2, 3, 4, 5}) {
+			t.Errorf("With seed %d, one of ones and generated pieces have ids %v.", seed, all)
+		}
+		// The same seed places them the same way.
+		again, _ := place(seed)
+		for id, p := range placed {
+			if q := again[id]; q == nil || q.OneOfOne.Name != p.OneOfOne.Name {
+				t.Errorf("With seed %d, %q was placed at %d, then elsewhere.", seed, p.OneOfOne.Name, id)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		oneOfOnes []*OneOfOne
+		want      string
+	}{
+		{[]*OneOfOne{{Name: "A"}, {Name: "B"}, {Name: "C"}}, "3 one of ones are configured, but the supply is only 2."},
+		{[]*OneOfOne{{Name: "Far", Position: 3}}, `One of one "Far" has position 3, beyond the supply of 2.`},
+	} {
+		c := testConfig()
+		c.OneOfOnes = test.oneOfOnes
+		_, _, err := NewCollectionGenerator(c, WithSupply(2)).placeOneOfOnes()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Expected %q, got %v.", test.want, err)
+		}
+	}
+}
+
+func TestCheckOneOfOnes(t *testing.T) {
+	dir := t.TempDir()
+	writeLayer(t, dir, "unique.png", 8, 8)
+	unique := filepath.Join(dir, "unique.png")
+	for _, test := range []struct {
+		oneOfOnes []*OneOfOne
+		want      string
+	}{
+		{[]*OneOfOne{{Name: "A", Path: unique, Position: 1}, {Name: "B", Path: unique}}, ""},
+		{[]*OneOfOne{{Name: "A"}}, `One of one "A" has no image path.`},
+		{[]*OneOfOne{{Name: "A", Path: filepath.Join(dir, "missing.png")}}, `One of one "A" image is missing`},
+		{[]*OneOfOne{{Name: "A", Path: unique, Position: 1}, {Name: "B", Path: unique, Position: 1}}, `One of one "B" has the same position, 1, as "A".`},
+	} {
+		c := testConfig()
+		c.OneOfOnes = test.oneOfOnes
+		errs := c.checkOneOfOnes()
+		if test.want == "" {
+			if len(errs) > 0 {
+				t.Errorf("Expected one of ones to pass, got:\n%s", errs)
+			}
+			continue
+		}
+		if len(errs) == 0 || !strings.Contains(errs.Error(), test.want) {
+			t.Errorf("Expected %q, got:\n%s", test.want, errs)
+		}
+	}
+}
+
+func TestGenerateOneOfOnes(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	writeLayer(t, dir, "unique.png", 8, 8)
+	c := testConfig()
+	c.OneOfOnes = []*OneOfOne{{Name: "Unique", Path: filepath.Join(dir, "unique.png"), Position: 2}}
+	g := NewCollectionGenerator(c, WithSupply(3), WithOutDir(out), WithPattern("%d"), WithSeed(1))
+	if _, err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.Pieces[1]; p.Id != 2 || p.OneOfOne == nil {
+		t.Fatalf("Expected piece #2 to be the one of one, got %+v.", p)
+	}
+	want, err := os.ReadFile(c.OneOfOnes[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(out, "2.png")); err != nil || string(got) != string(want) {
+		t.Errorf("Expected the one of one's image to be copied as 2.png: %v", err)
+	}
+	// Only generated pieces are read back.
+	pieces, err := ReadPieces(c, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 2 || pieces[0].Id != 1 || pieces[1].Id != 3 {
+		t.Errorf("Expected pieces #1 and #3 to be read back, got %d pieces.", len(pieces))
+	}
+}
@@ -0,0 +1,83 @@
+package artwork
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// picker picks an asset for a configured region, t, from its candidates, while a Piece is built. The traits picked so far are given in picked. A nil asset leaves the region empty.
+type picker interface {
+	pick(c *Configuration, t *Region, picked, candidates []*Asset) (*Asset, error)
+}
+
+// resetter is implemented by pickers which hold state for the piece being built, and must be reset before it is rebuilt.
+type resetter interface {
+	reset()
+}
+
+// retryError is returned by a picker when the piece being built has run into a dead end, and should be rebuilt.
+type retryError struct {
+	error
+}
+
+// pickIndex returns the index of the weight chosen by f, a number in [0.0, 1.0), where each weight's chance of being chosen is proportional to its size. Weights of zero or less are never chosen. Returns -1 if there is nothing to choose.
+func pickIndex(weights []float64, f float64) int {
+	var sum float64
+	for _, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+	}
+	if sum <= 0 {
+		return -1
+	}
+	threshold, last := f*sum, -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if threshold < w {
+			return i
+		}
+		threshold -= w
+		last = i
+	}
+	// Guard against floating point error.
+	return last
+}
+
+// randomPicker picks assets at random, by weight, as adjusted by any conditions on the traits already picked.
+type randomPicker struct {
+	rand *rand.Rand
+}
+
+func (rp *randomPicker) pick(c *Configuration, t *Region, picked, candidates []*Asset) (*Asset, error) {
+	return c.sampler(t, picked, candidates).Pick(rp.rand.Float64()), nil
+}
+
+// dnaPicker picks the assets described by a sequence of genes, in order.
+type dnaPicker struct {
+	genes []int
+	next  int
+}
+
+func (dp *dnaPicker) pick(c *Configuration, t *Region, picked, candidates []*Asset) (*Asset, error) {
+	if dp.next >= len(dp.genes) {
+		return nil, fmt.Errorf("DNA is too short for the composition tree; it has only %d genes.", len(dp.genes))
+	}
+	g := dp.genes[dp.next]
+	dp.next++
+	if g == emptyGene {
+		return nil, nil
+	}
+	if g >= len(c.Assets) {
+		return nil, fmt.Errorf("Gene %d refers to asset %d, but only %d are configured.", dp.next-1, g, len(c.Assets))
+	}
+	a := c.Assets[g]
+	for _, ca := range candidates {
+		if ca == a {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("Gene %d refers to asset %q, of kind %q, which cannot be placed in a region of kinds %v.", dp.next-1, a.Name, a.Kind, t.Kinds)
+}
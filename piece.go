@@ -5,13 +5,24 @@ import (
 	"image"
 	"image/draw"
 	"log"
+	"math/rand"
+
+	"github.com/Jsewill/chia/nft/metadata"
 )
 
+// maxDepth limits how deep a composition tree may grow, guarding against configurations in which an asset's regions accept its own kind.
+const maxDepth = 64
+
 // Piece represents a piece of artwork, with a *Region slice, Regions, for defining the composition tree, and an image.Image onto which it is to be composited, Canvas.
 type Piece struct {
 	Id uint
 	// @TODO: add attributes and a way to set them. Perhaps, a func type.
 	*Asset
+	Traits   []*Asset  // The configured assets picked for the piece by Build, in the order they were picked.
+	DNA      string    // Encodes the picks, so that the piece may be rebuilt with BuildDNA.
+	Rarity   *Rarity   // Set once the piece has been ranked among its collection, with Rank.
+	OneOfOne *OneOfOne // Set if the piece is a hand-crafted one of one, rather than generated.
+	genes    []int     // The index of each pick among its candidates, from which DNA is encoded.
 }
 
 // NewPiece creates a new piece from a base image.Image, canvas, or creates new image from bounds.
@@ -23,20 +34,171 @@ func NewPiece(id uint, canvas image.Image, bounds *image.Rectangle) *Piece {
 			canvas = image.NewNRGBA(image.Rectangle{})
 		} else {
 			// At least we got bounds. Create canvas from bounds.
-			canvas = image.NewNRGBA(&bounds)
+			canvas = image.NewNRGBA(*bounds)
 		}
 	}
 	return &Piece{Id: id, Asset: &Asset{Image: canvas}}
 }
 
-// Build creates an asset tree from a set of asset configuration data. Returns nil on succes, error on failure.
-func (p *Piece) Build() error {
+// Build creates an asset tree from a set of asset configuration data, c, starting from the root asset's Regions. For each Region, an Asset whose Kind matches one of Region.Kinds, and which the configured Rules allow alongside those already picked, is picked by weight, using the random source, rnd. The chosen asset's own Regions are built in turn. If the finished tree breaks a rule, it is rebuilt. The piece's DNA is set from the assets picked. Returns nil on success, error on failure.
+func (p *Piece) Build(c *Configuration, rnd *rand.Rand) error {
+	if rnd == nil {
+		err := fmt.Errorf("Failed to build piece #%d: no random source.", p.Id)
+		logErr.Println(err)
+		return err
+	}
+	return p.buildWith(c, &randomPicker{rand: rnd})
+}
+
+// buildWith builds the piece's asset tree from c, using pick, rebuilding it while it breaks a rule, or pick runs into a dead end.
+func (p *Piece) buildWith(c *Configuration, pick picker) error {
+	for attempt := 1; ; attempt++ {
+		err := p.grow(c, pick)
+		if _, retry := err.(retryError); err != nil && !retry {
+			return err
+		}
+		if err == nil {
+			err = c.Satisfied(p.Traits)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxRuleAttempts {
+			err := fmt.Errorf("Failed to build piece #%d: could not satisfy the rules in %d attempts. %s", p.Id, attempt, err)
+			logErr.Println(err)
+			return err
+		}
+	}
+}
+
+// BuildDNA recreates the asset tree described by dna, as produced by Build, from a set of asset configuration data, c. Returns nil on success, or an error if dna is malformed, or does not fit the configuration.
+func (p *Piece) BuildDNA(c *Configuration, dna string) error {
+	genes, err := DecodeDNA(dna)
+	if err != nil {
+		err := fmt.Errorf("Failed to build piece #%d: %s", p.Id, err)
+		logErr.Println(err)
+		return err
+	}
+	dp := &dnaPicker{genes: genes}
+	if err := p.grow(c, dp); err != nil {
+		return err
+	}
+	if dp.next != len(genes) {
+		err := fmt.Errorf("Failed to build piece #%d: DNA %q has %d unused genes.", p.Id, dna, len(genes)-dp.next)
+		logErr.Println(err)
+		return err
+	}
+	if err := c.Satisfied(p.Traits); err != nil {
+		err := fmt.Errorf("Failed to build piece #%d: DNA %q %s", p.Id, dna, err)
+		logErr.Println(err)
+		return err
+	}
+	return nil
+}
+
+// grow creates the piece's asset tree from c, using pick to choose an asset for each region. Errors from pick which call for a rebuild are returned as they are, for the caller to handle.
+func (p *Piece) grow(c *Configuration, pick picker) error {
+	if c == nil || c.Root == nil {
+		err := fmt.Errorf("Failed to build piece #%d: no root asset configured.", p.Id)
+		logErr.Println(err)
+		return err
+	}
+	// Release anything held from a previous build.
+	if r, ok := pick.(resetter); ok {
+		r.reset()
+	}
+	// Use the root's image as the canvas, unless one was supplied.
+	if (p.Asset.Image == nil || p.Asset.Image.Bounds().Empty()) && c.Root.IsLoaded() {
+		p.Asset.Image = c.Root.Image
+	}
 	// Build tree from Configuration.
-	//
+	p.Traits = make([]*Asset, 0)
+	p.genes = make([]int, 0)
+	regions, err := p.build(c, pick, c.Root.Regions, 0)
+	if _, retry := err.(retryError); retry {
+		return err
+	}
+	if err != nil {
+		err := fmt.Errorf("Failed to build piece #%d: %s", p.Id, err)
+		logErr.Println(err)
+		return err
+	}
+	p.Asset.Regions = regions
+	p.Asset.Parts = c.Root.cloneParts()
+	for _, part := range p.Asset.Parts {
+		c.inherit(part)
+	}
+	p.DNA = EncodeDNA(p.genes)
 	return nil
 }
 
-// Composite walks Regions, attempting to composite the entire composition tree onto the canvas.
+// build creates a branch of the composition tree from a slice of configured regions, templates, recursing into the regions of each asset it picks.
+func (p *Piece) build(c *Configuration, pick picker, templates []*Region, depth int) ([]*Region, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("Composition tree is deeper than %d regions. Do any assets have regions which accept their own kind?", maxDepth)
+	}
+	regions := make([]*Region, 0, len(templates))
+	for _, t := range templates {
+		region := t.clone()
+		c.inherit(region)
+		regions = append(regions, region)
+		// Pick an asset for this region.
+		picked, err := pick.pick(c, t, p.Traits, c.constrain(p.Traits, c.Candidates(t)))
+		if err != nil {
+			return nil, err
+		}
+		if picked == nil {
+			// Nothing to place here. Leave the region empty.
+			p.genes = append(p.genes, emptyGene)
+			continue
+		}
+		p.genes = append(p.genes, c.indexOf(picked))
+		p.Traits = append(p.Traits, picked)
+		a := picked.clone()
+		for _, part := range a.Parts {
+			c.inherit(part)
+		}
+		a.Parent = region
+		region.Asset = a
+		// Climb the tree.
+		branch, err := p.build(c, pick, picked.Regions, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		a.Regions = branch
+	}
+	return regions, nil
+}
+
+// inherit gives a region of a piece the configuration's interpolation, and pixel art mode, unless it has its own.
+func (c *Configuration) inherit(region *Region) {
+	if region.Interpolation == InterpolateDefault {
+		region.Interpolation = c.Interpolation
+	}
+	region.PixelArt = region.PixelArt || c.PixelArt
+}
+
+// retrace recomputes the piece's traits and DNA from its composition tree, after branches of the tree have been changed.
+func (p *Piece) retrace(c *Configuration) {
+	p.Traits = make([]*Asset, 0, len(p.Traits))
+	p.genes = make([]int, 0, len(p.genes))
+	var walk func(regions []*Region)
+	walk = func(regions []*Region) {
+		for _, region := range regions {
+			if region.Asset == nil {
+				p.genes = append(p.genes, emptyGene)
+				continue
+			}
+			p.genes = append(p.genes, c.indexOf(region.Asset.origin))
+			p.Traits = append(p.Traits, region.Asset.origin)
+			walk(region.Asset.Regions)
+		}
+	}
+	walk(p.Asset.Regions)
+	p.DNA = EncodeDNA(p.genes)
+}
+
+// Composite walks Regions, attempting to composite the entire composition tree onto the canvas. Text assets are rendered for the piece first, from its Id and attributes.
 func (p *Piece) Composite() error {
 	// Check for canvas.
 	if p.Asset.Image == nil {
@@ -46,42 +208,65 @@ func (p *Piece) Composite() error {
 	}
 	// Check for composition tree trunk.
 	if len(p.Regions) == 0 {
-		err := fmt.Errorf("Failed to composit piece, no tree to composite.")
+		err := fmt.Errorf("Failed to composite piece, no tree to composite.")
+		logErr.Println(err)
+		return err
 	}
 	// Find non-nil branches.
 	var anyRegion bool
 	for _, region := range p.Regions {
-		// No region here, move on.
-		if region == nil {
-			continue
+		if region != nil {
+			anyRegion = true
+			break
 		}
-		anyRegion = true
-		// Climb the tree!
-		comp, err := region.Composite()
-		if err != nil {
-			err := fmt.Errorf("Failed to composite piece: %s", err)
-			logErr.Println(err)
-			return err
-		}
-		// Expand the current canvas if necessary.
-		if !comp.Bounds().In(p.Asset.Image.Bounds()) {
-			// Replace the old canvas with the new one.
-			p.Asset.Image = GrowImage(p.Asset.Image, comp.Bounds())
-		}
-		// Composite onto canvas. @TODO: Include size here
-		offset := CenterOffset(region.Coordinates(), comp.Bounds())
-		draw.Over.Draw(p.Asset.Image, p.Asset.Image.Bounds(), comp, offset)
 	}
 	// Check that we had at least one branch to climb.
 	if !anyRegion {
 		err := fmt.Errorf("Failed to composite piece, no regions were initialized.")
 		logErr.Println(err)
 		return err
-
 	}
-	// We successfully composited every branch.
-	// Now we composite the result with the piece.
-	// @TODO: final composite
-	log.Println("Composited Piece, %v", p)
+	// Render text for this piece.
+	if err := p.renderText(); err != nil {
+		err := fmt.Errorf("Failed to composite piece: %s", err)
+		logErr.Println(err)
+		return err
+	}
+	// Climb the tree!
+	comp, err := p.Asset.Composite()
+	if err != nil {
+		err := fmt.Errorf("Failed to composite piece: %s", err)
+		logErr.Println(err)
+		return err
+	}
+	// We successfully composited every branch. Crop the result back to the canvas, as branches may have grown it.
+	bounds := p.Asset.Image.Bounds()
+	canvas := image.NewNRGBA(bounds)
+	draw.Draw(canvas, bounds, comp, bounds.Min, draw.Src)
+	p.Asset.Image = canvas
+	log.Printf("Composited piece #%d.\n", p.Id)
 	return nil
 }
+
+// Attributes returns the attributes given to the piece by its traits, in the order they were picked, or those of its one of one.
+func (p *Piece) Attributes() []Attribute {
+	if p.OneOfOne != nil {
+		return append([]Attribute(nil), p.OneOfOne.Attributes...)
+	}
+	attrs := make([]Attribute, len(p.Traits))
+	for i, t := range p.Traits {
+		attrs[i] = t.Attribute()
+	}
+	return attrs
+}
+
+// MetadataAttributes returns the piece's attributes as CHIP-0007 metadata attributes, leaving out those which are hidden.
+func (p *Piece) MetadataAttributes() []*metadata.Attribute {
+	attrs := make([]*metadata.Attribute, 0, len(p.Traits))
+	for _, a := range p.Attributes() {
+		if !a.Hidden {
+			attrs = append(attrs, a.Metadata())
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,110 @@
+package artwork
+
+import (
+	"image"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testConfig returns a small configuration, held in memory: a background and a hat, of two traits each, where the crown has a region of its own, for a jewel. It allows 4 unique pieces.
+func testConfig() *Configuration {
+	blank := func(size int) image.Image {
+		return image.NewNRGBA(image.Rect(0, 0, size, size))
+	}
+	c := NewConfiguration()
+	c.Root = &Asset{Image: blank(8)}
+	c.Root.Regions = []*Region{
+		{Coords: &image.Point{4, 4}, Kinds: []string{"background"}},
+		{Coords: &image.Point{4, 2}, Kinds: []string{"hat"}},
+	}
+	crown := &Asset{Kind: "hat", Name: "Crown", Weight: 0.25, Image: blank(4)}
+	crown.Regions = []*Region{{Coords: &image.Point{2, 0}, Kinds: []string{"jewel"}}}
+	c.Assets = []*Asset{
+		{Kind: "background", Name: "Red", Weight: 0.5, Image: blank(8)},
+		{Kind: "background", Name: "Blue", Weight: 0.5, Image: blank(8)},
+		{Kind: "hat", Name: "Cap", Weight: 0.75, Image: blank(4)},
+		crown,
+		{Kind: "jewel", Name: "Ruby", Weight: 1, Image: blank(1)},
+	}
+	return c
+}
+
+// traitNames returns the traits picked for a piece, as "kind/name", in the order they were picked.
+func traitNames(p *Piece) []string {
+	names := make([]string, len(p.Traits))
+	for i, a := range p.Traits {
+		names[i] = a.Kind + "/" + a.Name
+	}
+	return names
+}
+
+func TestBuild(t *testing.T) {
+	c := testConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for seed := int64(0); seed < 50; seed++ {
+		p := NewPiece(1, nil, nil)
+		if err := p.Build(c, rand.New(rand.NewSource(seed))); err != nil {
+			t.Fatal(err)
+		}
+		names := traitNames(p)
+		seen[strings.Join(names, ",")] = true
+		if len(p.Asset.Regions) != 2 {
+			t.Fatalf("Expected the root's 2 regions to be built, got %d.", len(p.Asset.Regions))
+		}
+		for i, region := range p.Asset.Regions {
+			a := region.Asset
+			if a == nil {
+				t.Fatalf("Region %d was left empty.", i)
+			}
+			if a.Parent != region || a.origin != p.Traits[i] {
+				t.Errorf("Region %d holds a misplaced asset.", i)
+			}
+			for _, k := range c.Root.Regions[i].Kinds {
+				if a.Kind != k {
+					t.Errorf("Region %d, of kind %s, holds %s.", i, k, a.Kind+"/"+a.Name)
+				}
+			}
+			if a == a.origin {
+				t.Errorf("Region %d holds the configured asset itself, rather than a copy.", i)
+			}
+		}
+		// The crown's region is built in turn.
+		crowned := names[1] == "hat/Crown"
+		if crowned != (len(names) == 3) || crowned && names[2] != "jewel/Ruby" {
+			t.Errorf("Expected a jewel if, and only if, a crown was picked, got %v.", names)
+		}
+		if p.DNA == "" {
+			t.Errorf("Piece has no DNA.")
+		}
+		if p.Asset.Image != c.Root.Image {
+			t.Errorf("Expected the root's image to be used as the canvas.")
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("Expected every one of 4 combinations over 50 seeds, got %v.", seen)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	p := NewPiece(1, nil, nil)
+	if err := p.Build(testConfig(), nil); err == nil {
+		t.Error("Expected building without a random source to fail.")
+	}
+	if err := p.Build(NewConfiguration(), rand.New(rand.NewSource(1))); err == nil {
+		t.Error("Expected building without a root asset to fail.")
+	}
+	// An asset whose region accepts its own kind grows without end.
+	c := testConfig()
+	nest := &Asset{Kind: "nest", Name: "Nest", Weight: 1, Image: image.NewNRGBA(image.Rect(0, 0, 1, 1))}
+	nest.Regions = []*Region{{Kinds: []string{"nest"}}}
+	c.Assets = append(c.Assets, nest)
+	c.Root.Regions = append(c.Root.Regions, &Region{Kinds: []string{"nest"}})
+	err := p.Build(c, rand.New(rand.NewSource(1)))
+	if err == nil || !strings.Contains(err.Error(), "deeper than") {
+		t.Errorf("Expected a tree which nests without end to fail, got %v.", err)
+	}
+}
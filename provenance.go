@@ -0,0 +1,386 @@
+package artwork
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Jsewill/chia/nft"
+)
+
+// ProvenanceFile is the base name of the file, in the output directory, to which a CollectionGenerator writes the collection's provenance record, as JSON.
+const ProvenanceFile = "provenance"
+
+/*
+Provenance is a collection's provenance record, published before mint, so that buyers may verify the collection was not reordered after its rarity was known.
+
+ImageHashes holds the SHA-256 hash of every piece's image, in generation order, and Hash the SHA-256 hash of those hashes, concatenated, as hex, in that order. Commitment is the hash of the reveal seed, as computed by RevealCommitment, which fixes the seed without disclosing it. Once revealed, RevealSeed holds the seed, Entropy the public entropy it was mixed with, and TokenIds the final token id given to each piece, in generation order, as computed by RevealOrder. Pieces at Fixed ids are not reordered.
+*/
+type Provenance struct {
+	Hash        string   `json:"provenance_hash"`
+	ImageHashes []string `json:"image_hashes"`
+	Commitment  string   `json:"reveal_commitment,omitempty"`
+	Fixed       []uint   `json:"fixed_ids,omitempty"`
+	RevealSeed  *int64   `json:"reveal_seed,omitempty"`
+	Entropy     string   `json:"reveal_entropy,omitempty"`
+	TokenIds    []uint   `json:"token_ids,omitempty"`
+}
+
+// ProvenanceHash computes the provenance hash of a collection from the hex encoded SHA-256 hashes of its images, in generation order: the SHA-256 hash of the hashes, concatenated. Returns the hex encoded hash.
+func ProvenanceHash(imageHashes []string) string {
+	h := sha256.Sum256([]byte(strings.Join(imageHashes, "")))
+	return hex.EncodeToString(h[:])
+}
+
+// RevealCommitment computes the commitment to a reveal seed, published in advance of the reveal: the hex encoded SHA-256 hash of the provenance hash, a colon, and the seed in decimal. As the commitment can be checked against every seed, the seed must be hard to guess.
+func RevealCommitment(provenanceHash string, seed int64) string {
+	h := sha256.Sum256([]byte(provenanceHash + ":" + strconv.FormatInt(seed, 10)))
+	return hex.EncodeToString(h[:])
+}
+
+/*
+RevealOrder computes the final token ids of a collection's pieces from a reveal seed, public entropy, and their ids in generation order. The ids, except those which are fixed, are shuffled with the seed and entropy mixed together, by RevealSource.
+
+The seed is chosen by the creator, who could try seeds until one gave the rarest pieces to favored token ids. Entropy which does not exist until after mint, such as the hash of a block mined once minting has closed, prevents that: the order can not be known until the entropy exists, and the committed seed can not be changed once it does. Returns the final token id of each piece, in generation order.
+*/
+func RevealOrder(seed int64, entropy string, ids []uint, fixed []uint) []uint {
+	isFixed := make(map[uint]bool, len(fixed))
+	for _, id := range fixed {
+		isFixed[id] = true
+	}
+	// Shuffle the ids which may move, and put them back in the places of those which may.
+	movable := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !isFixed[id] {
+			movable = append(movable, id)
+		}
+	}
+	rnd := rand.New(rand.NewSource(RevealSource(seed, entropy)))
+	rnd.Shuffle(len(movable), func(i, j int) {
+		movable[i], movable[j] = movable[j], movable[i]
+	})
+	order := make([]uint, len(ids))
+	for i, id := range ids {
+		if isFixed[id] {
+			order[i] = id
+			continue
+		}
+		order[i], movable = movable[0], movable[1:]
+	}
+	return order
+}
+
+// RevealSource mixes a reveal seed with public entropy, as the first 8 bytes of the SHA-256 hash of the seed in decimal, a colon, and the entropy. Returns the source from which the reveal order is shuffled.
+func RevealSource(seed int64, entropy string) int64 {
+	h := sha256.Sum256([]byte(strconv.FormatInt(seed, 10) + ":" + entropy))
+	return int64(binary.BigEndian.Uint64(h[:8]))
+}
+
+// LoadProvenance reads a provenance record, as written by a CollectionGenerator, from the JSON file at path. Returns the *Provenance, or an error if it could not be read.
+func LoadProvenance(path string) (*Provenance, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load provenance record: %s", err)
+	}
+	pr := new(Provenance)
+	if err := json.Unmarshal(b, pr); err != nil {
+		return nil, fmt.Errorf("Failed to load provenance record %q: %s", path, err)
+	}
+	return pr, nil
+}
+
+// Verify checks the provenance record against the hashes of a collection's images, in generation order, and, once revealed, the reveal seed against the commitment, and the token ids against the seed and entropy. Returns an error describing the first discrepancy, or nil.
+func (pr *Provenance) Verify(imageHashes []string) error {
+	if len(imageHashes) != len(pr.ImageHashes) {
+		return fmt.Errorf("Provenance lists %d images, but %d were given.", len(pr.ImageHashes), len(imageHashes))
+	}
+	for i, h := range imageHashes {
+		if h != pr.ImageHashes[i] {
+			return fmt.Errorf("Image %d has hash %s, but provenance lists %s.", i+1, h, pr.ImageHashes[i])
+		}
+	}
+	if h := ProvenanceHash(imageHashes); h != pr.Hash {
+		return fmt.Errorf("Provenance hash is %s, but the images hash to %s.", pr.Hash, h)
+	}
+	if pr.RevealSeed == nil {
+		return nil
+	}
+	if pr.Commitment != "" && RevealCommitment(pr.Hash, *pr.RevealSeed) != pr.Commitment {
+		return fmt.Errorf("Reveal seed %d does not match the commitment, %s.", *pr.RevealSeed, pr.Commitment)
+	}
+	ids := make([]uint, len(imageHashes))
+	for i := range ids {
+		ids[i] = uint(i + 1)
+	}
+	order := RevealOrder(*pr.RevealSeed, pr.Entropy, ids, pr.Fixed)
+	if len(order) != len(pr.TokenIds) {
+		return fmt.Errorf("Provenance lists %d token ids, but there are %d images.", len(pr.TokenIds), len(order))
+	}
+	for i, id := range order {
+		if pr.TokenIds[i] != id {
+			return fmt.Errorf("Piece %d has token id %d, but the reveal seed gives it %d.", i+1, pr.TokenIds[i], id)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the provenance record to w, as JSON.
+func (pr *Provenance) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(pr)
+}
+
+// provenance builds the collection's provenance record from the hashes of its images, committing to the reveal seed, if one is set.
+func (g *CollectionGenerator) provenance(c nft.Collection) *Provenance {
+	pr := &Provenance{ImageHashes: make([]string, 0, len(c.NFTs))}
+	for _, n := range c.NFTs {
+		pr.ImageHashes = append(pr.ImageHashes, n.Asset.Hash)
+	}
+	pr.Hash = ProvenanceHash(pr.ImageHashes)
+	if g.RevealSeed != nil {
+		pr.Commitment = RevealCommitment(pr.Hash, *g.RevealSeed)
+	}
+	for _, p := range g.Pieces {
+		if p.OneOfOne != nil && p.OneOfOne.Position != 0 {
+			pr.Fixed = append(pr.Fixed, p.Id)
+		}
+	}
+	return pr
+}
+
+// writeProvenance writes the collection's provenance record to the output directory.
+func (g *CollectionGenerator) writeProvenance() error {
+	_, err := writeHashed(filepath.Join(g.OutDir, ProvenanceFile+".json"), g.Provenance.WriteJSON)
+	return err
+}
+
+/*
+Reveal gives each generated piece its final token id, as determined by the reveal seed, seed, and public entropy, entropy, once the provenance record has been published and the collection minted. The seed is checked against the commitment, if one was made. Entropy must be something neither the creator nor anyone else could choose or know at mint, such as the hash of a block mined after minting closes; see RevealOrder. Each piece's files are renamed for its new id, and its metadata, the rarity ranking and the provenance record are rewritten. The new metadata is written before anything is renamed, and, should anything fail, everything done is undone, leaving the collection as it was. One of ones placed at a position of their own keep it. A collection generated by an earlier process may be revealed once read back with Load.
+
+Returns an nft.Collection, ordered by token id, or an error if the seed does not match the commitment, no entropy was given, or any file could not be rewritten.
+*/
+func (g *CollectionGenerator) Reveal(seed int64, entropy string) (nft.Collection, error) {
+	var c nft.Collection
+	pr := g.Provenance
+	if pr == nil || len(g.Pieces) == 0 {
+		err := fmt.Errorf("Failed to reveal collection: nothing has been generated.")
+		logErr.Println(err)
+		return c, err
+	}
+	if pr.RevealSeed != nil {
+		err := fmt.Errorf("Failed to reveal collection: it was already revealed, with seed %d.", *pr.RevealSeed)
+		logErr.Println(err)
+		return c, err
+	}
+	if entropy == "" {
+		err := fmt.Errorf("Failed to reveal collection: no entropy was given. Use a value no one could choose or know at mint, such as the hash of a block mined after minting closes.")
+		logErr.Println(err)
+		return c, err
+	}
+	if pr.Commitment != "" && RevealCommitment(pr.Hash, seed) != pr.Commitment {
+		err := fmt.Errorf("Failed to reveal collection: seed %d does not match the commitment, %s.", seed, pr.Commitment)
+		logErr.Println(err)
+		return c, err
+	}
+	ids, paths := make([]uint, len(g.Pieces)), make([]string, len(g.Pieces))
+	for i, p := range g.Pieces {
+		ids[i], paths[i] = p.Id, p.Path
+	}
+	order := RevealOrder(seed, entropy, ids, pr.Fixed)
+	pieces, done, aside := g.Pieces, renames{}, make([]string, 0)
+	// On failure, undo everything done so far, leaving the collection as it was.
+	fail := func(err error) (nft.Collection, error) {
+		done.undo()
+		for i, p := range pieces {
+			os.Remove(filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, order[i])) + ".json.new")
+			p.Id, p.Path = ids[i], paths[i]
+			if p.Rarity != nil {
+				p.Rarity.Id = p.Id
+			}
+		}
+		g.Pieces = pieces
+		pr.RevealSeed, pr.Entropy, pr.TokenIds = nil, "", nil
+		err = fmt.Errorf("Failed to reveal collection: %s", err)
+		logErr.Println(err)
+		return c, err
+	}
+	// Write the revealed metadata beside the old first, so that nothing is lost if any of it fails.
+	revealed := make([]*nft.Nft, len(g.Pieces))
+	for i, p := range g.Pieces {
+		p.Id = order[i]
+		if p.Rarity != nil {
+			p.Rarity.Id = p.Id
+		}
+		p.Path = filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, p.Id)) + filepath.Ext(paths[i])
+		metaPath, metaHash, err := g.writeMetadata(p, ".new")
+		if err != nil {
+			return fail(err)
+		}
+		revealed[p.Id-1] = &nft.Nft{
+			Asset:    &nft.Asset{Uris: []string{p.Path}, Hash: pr.ImageHashes[i]},
+			Metadata: &nft.Asset{Uris: []string{metaPath}, Hash: metaHash},
+			License:  &nft.Asset{},
+		}
+	}
+	// Move the old files aside, so that no piece's new name clobbers another's old one, then move the new into place.
+	for i := range g.Pieces {
+		meta := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, ids[i])) + ".json"
+		if err := done.rename(paths[i], paths[i]+".old"); err != nil {
+			return fail(err)
+		}
+		if err := done.rename(meta, meta+".old"); err != nil && !os.IsNotExist(err) {
+			return fail(err)
+		}
+		aside = append(aside, meta+".old")
+	}
+	for i, p := range g.Pieces {
+		meta := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, p.Id)) + ".json"
+		if err := done.rename(paths[i]+".old", p.Path); err != nil {
+			return fail(err)
+		}
+		if err := done.rename(meta+".new", meta); err != nil {
+			return fail(err)
+		}
+	}
+	for _, name := range []string{RarityFile + ".csv", RarityFile + ".json", ProvenanceFile + ".json"} {
+		path := filepath.Join(g.OutDir, name)
+		if err := done.rename(path, path+".old"); err != nil && !os.IsNotExist(err) {
+			return fail(err)
+		}
+		aside = append(aside, path+".old")
+	}
+	pr.RevealSeed, pr.Entropy, pr.TokenIds = &seed, entropy, order
+	// Keep the pieces, and their ranking, in token id order.
+	g.Pieces = make([]*Piece, len(pieces))
+	for _, p := range pieces {
+		g.Pieces[p.Id-1] = p
+	}
+	if err := g.writeRarities(); err != nil {
+		return fail(err)
+	}
+	if err := g.writeProvenance(); err != nil {
+		return fail(err)
+	}
+	for _, path := range aside {
+		os.Remove(path)
+	}
+	c.NFTs = revealed
+	log.Printf("Revealed %d pieces in %q, with seed %d and entropy %q.\n", len(g.Pieces), g.OutDir, seed, entropy)
+	return c, nil
+}
+
+// renames records files renamed, so that the renames may be undone.
+type renames [][2]string
+
+// rename renames a file, from, to a path, to, and records it, if renamed.
+func (r *renames) rename(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	*r = append(*r, [2]string{from, to})
+	return nil
+}
+
+// undo undoes the recorded renames, last first.
+func (r renames) undo() {
+	for i := len(r) - 1; i >= 0; i-- {
+		if err := os.Rename(r[i][1], r[i][0]); err != nil {
+			logErr.Println(err)
+		}
+	}
+}
+
+/*
+Load reads a collection generated earlier back from OutDir, so that a later process may reveal it: the provenance record, and the pieces, rebuilt from the DNA in their metadata, with the one of ones placed again and the pieces ranked again. The generator must be configured as it was to generate the collection, with the same Config, Name, Pattern, Seed and RarityMethod. Supply is taken from the provenance record. Every piece's image is checked against the provenance record, so a generator configured otherwise fails to load rather than misplacing pieces.
+
+Returns an error if anything could not be read, or does not match the provenance record.
+*/
+func (g *CollectionGenerator) Load() error {
+	if g.Config == nil {
+		err := fmt.Errorf("Failed to load collection: no configuration.")
+		logErr.Println(err)
+		return err
+	}
+	pr, err := LoadProvenance(filepath.Join(g.OutDir, ProvenanceFile+".json"))
+	if err != nil {
+		logErr.Println(err)
+		return err
+	}
+	g.Supply = uint(len(pr.ImageHashes))
+	if pr.RevealSeed != nil && len(pr.TokenIds) != len(pr.ImageHashes) {
+		err := fmt.Errorf("Failed to load collection: provenance lists %d token ids, but %d images.", len(pr.TokenIds), len(pr.ImageHashes))
+		logErr.Println(err)
+		return err
+	}
+	oneOfOnes, ids, err := g.placeOneOfOnes()
+	if err != nil {
+		err := fmt.Errorf("Failed to load collection: %s", err)
+		logErr.Println(err)
+		return err
+	}
+	read, err := ReadPieces(g.Config, g.OutDir)
+	if err != nil {
+		err := fmt.Errorf("Failed to load collection: %s", err)
+		logErr.Println(err)
+		return err
+	}
+	if len(read) != len(ids) {
+		err := fmt.Errorf("Failed to load collection: %d generated pieces were expected, but %d were found.", len(ids), len(read))
+		logErr.Println(err)
+		return err
+	}
+	byId := make(map[uint]*Piece, len(read))
+	for _, p := range read {
+		byId[p.Id] = p
+	}
+	// Pieces are found by their token ids, once revealed, and kept in token id order, as Reveal leaves them. Their images are hashed in generation order.
+	pieces := make([]*Piece, g.Supply)
+	hashes := make([]string, g.Supply)
+	for id := uint(1); id <= g.Supply; id++ {
+		token := id
+		if pr.RevealSeed != nil {
+			token = pr.TokenIds[id-1]
+		}
+		if token < 1 || token > g.Supply || pieces[token-1] != nil {
+			err := fmt.Errorf("Failed to load collection: provenance gives piece %d an invalid token id, %d.", id, token)
+			logErr.Println(err)
+			return err
+		}
+		base := filepath.Join(g.OutDir, fmt.Sprintf(g.Pattern, token))
+		p, ok := oneOfOnes[id]
+		if ok {
+			p.Id, p.Path = token, base+filepath.Ext(p.OneOfOne.Path)
+		} else if p, ok = byId[token]; ok {
+			p.Path = base + ".png"
+		} else {
+			err := fmt.Errorf("Failed to load collection: piece %d was not found.", token)
+			logErr.Println(err)
+			return err
+		}
+		if hashes[id-1], err = hashFile(p.Path); err != nil {
+			err := fmt.Errorf("Failed to load collection: %s", err)
+			logErr.Println(err)
+			return err
+		}
+		pieces[token-1] = p
+	}
+	if err := pr.Verify(hashes); err != nil {
+		err := fmt.Errorf("Failed to load collection: %s Is the generator configured as it was to generate it?", err)
+		logErr.Println(err)
+		return err
+	}
+	g.Rarities = Rank(read, g.RarityMethod)
+	g.Pieces, g.Provenance = pieces, pr
+	log.Printf("Loaded %d pieces from %q, with provenance hash %s.\n", len(g.Pieces), g.OutDir, pr.Hash)
+	return nil
+}
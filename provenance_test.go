@@ -0,0 +1,168 @@
+package artwork
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRevealOrder(t *testing.T) {
+	ids := []uint{1, 2, 3, 4, 5, 6, 7, 8}
+	order := RevealOrder(42, "block", ids, []uint{3})
+	if !reflect.DeepEqual(order, RevealOrder(42, "block", ids, []uint{3})) {
+		t.Fatal("Reveal order differs for the same seed.")
+	}
+	if order[2] != 3 {
+		t.Errorf("Expected fixed id 3 to keep its place, got %v.", order)
+	}
+	seen := make(map[uint]bool)
+	for _, id := range order {
+		if id < 1 || id > 8 || seen[id] {
+			t.Fatalf("Reveal order %v is not a permutation of %v.", order, ids)
+		}
+		seen[id] = true
+	}
+	// The seed alone does not determine the order.
+	for _, entropy := range []string{"other block", "yet another block"} {
+		if !reflect.DeepEqual(RevealOrder(42, entropy, ids, []uint{3}), order) {
+			return
+		}
+	}
+	t.Error("Expected the reveal order to depend on the entropy.")
+}
+
+func TestProvenanceVerify(t *testing.T) {
+	hashes := []string{"aa", "bb", "cc"}
+	seed := int64(7)
+	pr := &Provenance{
+		Hash:        ProvenanceHash(hashes),
+		ImageHashes: hashes,
+		Commitment:  RevealCommitment(ProvenanceHash(hashes), seed),
+	}
+	if err := pr.Verify(hashes); err != nil {
+		t.Fatal(err)
+	}
+	pr.RevealSeed, pr.Entropy, pr.TokenIds = &seed, "block", RevealOrder(seed, "block", []uint{1, 2, 3}, nil)
+	if err := pr.Verify(hashes); err != nil {
+		t.Fatal(err)
+	}
+	if err := pr.Verify([]string{"bb", "aa", "cc"}); err == nil {
+		t.Error("Expected reordered images to fail verification.")
+	}
+	pr.Entropy = "other block"
+	if err := pr.Verify(hashes); err == nil {
+		t.Error("Expected token ids which do not match the entropy to fail verification.")
+	}
+	pr.Entropy = "block"
+	other := int64(8)
+	pr.RevealSeed = &other
+	if err := pr.Verify(hashes); err == nil {
+		t.Error("Expected a seed which does not match the commitment to fail verification.")
+	}
+}
+
+func TestRevealLater(t *testing.T) {
+	dir := t.TempDir()
+	options := []GeneratorOption{WithName("Test"), WithOutDir(dir), WithSeed(5)}
+	c := testConfig()
+	unique := filepath.Join(t.TempDir(), "unique.png")
+	writeLayer(t, filepath.Dir(unique), "unique.png", 8, 8)
+	c.OneOfOnes = []*OneOfOne{{Name: "Unique", Path: unique}}
+	g := NewCollectionGenerator(c, append(options, WithSupply(5), WithRevealSeed(99))...)
+	if _, err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	dnas := make(map[string]bool)
+	for _, p := range g.Pieces {
+		dnas[p.DNA] = true
+	}
+	// A later process, knowing only the configuration and options, loads the collection, and reveals it.
+	later := NewCollectionGenerator(c, options...)
+	if err := later.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if later.Supply != 5 || len(later.Pieces) != 5 || later.Provenance.Hash != g.Provenance.Hash {
+		t.Fatalf("Loaded %d of %d pieces, with provenance hash %s, expected 5, with %s.", len(later.Pieces), later.Supply, later.Provenance.Hash, g.Provenance.Hash)
+	}
+	if _, err := later.Reveal(99, ""); err == nil {
+		t.Error("Expected revealing without entropy to fail.")
+	}
+	if _, err := later.Reveal(98, "block"); err == nil {
+		t.Error("Expected revealing with a seed other than that committed to to fail.")
+	}
+	if _, err := later.Reveal(99, "block"); err != nil {
+		t.Fatal(err)
+	}
+	// The revealed collection loads again, and verifies.
+	again := NewCollectionGenerator(c, options...)
+	if err := again.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if pr := again.Provenance; pr.RevealSeed == nil || *pr.RevealSeed != 99 || pr.Entropy != "block" || !reflect.DeepEqual(pr.TokenIds, later.Provenance.TokenIds) {
+		t.Errorf("Expected the revealed provenance record to be loaded, got %+v.", pr)
+	}
+	for i, p := range again.Pieces {
+		if p.Id != uint(i+1) || p.OneOfOne == nil && !dnas[p.DNA] {
+			t.Errorf("Loaded revealed piece %d as #%d, with DNA %q.", i+1, p.Id, p.DNA)
+		}
+	}
+	// A generator configured otherwise does not load.
+	if err := NewCollectionGenerator(c, WithOutDir(dir), WithSeed(5), WithPattern("piece-%d")).Load(); err == nil {
+		t.Error("Expected loading with another pattern to fail.")
+	}
+}
+
+func TestRevealFailure(t *testing.T) {
+	dir := t.TempDir()
+	g := NewCollectionGenerator(testConfig(), WithOutDir(dir), WithSupply(4), WithSeed(5), WithRevealSeed(99))
+	if _, err := g.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	// files reads every file in the output directory.
+	files := func() map[string]string {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := make(map[string]string)
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[e.Name()] = string(b)
+		}
+		return contents
+	}
+	before := files()
+	// A directory in the way of moving the provenance record aside fails the reveal after every piece has been renamed.
+	blocker := filepath.Join(dir, ProvenanceFile+".json.old")
+	writeLayer(t, blocker, "blocker.png", 1, 1)
+	if _, err := g.Reveal(99, "block"); err == nil {
+		t.Fatal("Expected the reveal to fail.")
+	}
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if after := files(); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected a failed reveal to leave the collection as it was, got files:\n%v\nexpected:\n%v", after, before)
+	}
+	for i, p := range g.Pieces {
+		if p.Id != uint(i+1) {
+			t.Errorf("Expected piece %d to keep its id, got #%d.", i+1, p.Id)
+		}
+	}
+	// Once out of the way, the reveal succeeds.
+	if _, err := g.Reveal(99, "block"); err != nil {
+		t.Fatal(err)
+	}
+	again := NewCollectionGenerator(testConfig(), WithOutDir(dir), WithSeed(5))
+	if err := again.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
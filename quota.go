@@ -0,0 +1,421 @@
+package artwork
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+)
+
+// Allocation is a method of allocating traits to the pieces of a collection.
+type Allocation int
+
+const (
+	// Probabilistic allocation picks each trait at random, by weight, so the number of pieces with a trait only tends toward its weight.
+	Probabilistic Allocation = iota
+	// Quota allocation converts weights into exact counts for the supply, with Apportion, and deals those counts out across the pieces.
+	Quota
+)
+
+// String returns the name of the allocation method.
+func (a Allocation) String() string {
+	switch a {
+	case Probabilistic:
+		return "probabilistic"
+	case Quota:
+		return "quota"
+	}
+	return fmt.Sprintf("Allocation(%d)", int(a))
+}
+
+/*
+Apportion divides n between weights, in proportion, using the largest remainder method. Each share is first rounded down, and whatever remains is handed out one at a time, in order of the largest fractional remainder. Ties go to the earlier weight, so the result is always the same for the same weights. Negative weights are treated as zero. For example:
+
+	Apportion([]float64{0.5, 0.3, 0.2}, 4) returns []int{2, 1, 1}
+*/
+func Apportion(weights []float64, n int) []int {
+	counts := make([]int, len(weights))
+	var sum float64
+	for _, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+	}
+	if sum <= 0 || n <= 0 {
+		return counts
+	}
+	remainders := make([]float64, len(weights))
+	given := 0
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		exact := w / sum * float64(n)
+		counts[i] = int(math.Floor(exact))
+		remainders[i] = exact - float64(counts[i])
+		given += counts[i]
+	}
+	// Hand out what remains, by largest remainder.
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := 0; given < n; i = (i + 1) % len(order) {
+		if weights[order[i]] <= 0 {
+			continue
+		}
+		counts[order[i]]++
+		given++
+	}
+	return counts
+}
+
+// Quotas computes the exact number of times each asset is to be picked for each configured region, over a supply of pieces. Top level regions appear in every piece, and the regions of an asset appear as many times as it is picked. Returns the quotas, keyed by configured region, then asset.
+func (c *Configuration) Quotas(supply uint) (map[*Region]map[*Asset]int, error) {
+	if c.Root == nil {
+		return nil, fmt.Errorf("No root asset.")
+	}
+	quotas := make(map[*Region]map[*Asset]int)
+	if err := c.quotas(quotas, c.Root.Regions, int(supply), 0); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// quotas apportions n appearances of each of templates between their candidates, adding the result to quotas, and recursing into the regions of each candidate given a share.
+func (c *Configuration) quotas(quotas map[*Region]map[*Asset]int, templates []*Region, n int, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("Composition tree is deeper than %d regions. Do any assets have regions which accept their own kind?", maxDepth)
+	}
+	for _, t := range templates {
+		candidates := c.Candidates(t)
+		weights := make([]float64, len(candidates))
+		for i, a := range candidates {
+			weights[i] = a.Weight
+		}
+		counts := Apportion(weights, n)
+		for i, a := range candidates {
+			if counts[i] == 0 {
+				continue
+			}
+			if quotas[t] == nil {
+				quotas[t] = make(map[*Asset]int)
+			}
+			quotas[t][a] += counts[i]
+			if err := c.quotas(quotas, a.Regions, counts[i], depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+checkQuotas checks that quotas, as computed by Quotas, may be dealt out without duplicating a piece: no trait may be dealt to a region more times than there are distinct composition trees which place it there. For example, with three hats, a background dealt to four pieces must be paired with the same hat twice. Rules are not taken into account, so quotas which pass may yet be impossible under them.
+
+Returns every quota found too large, as Errors, or an error if the composition tree is too deep to count.
+*/
+func (c *Configuration) checkQuotas(quotas map[*Region]map[*Asset]int) (Errors, error) {
+	total, err := c.Combinations()
+	if err != nil {
+		return nil, err
+	}
+	var errs Errors
+	var walkErr error
+	c.Walk(func(parent *Asset) {
+		for _, t := range parent.Regions {
+			// A region may be placed more than once in a tree, by an asset placed more than once.
+			occurrences := big.NewInt(int64(c.occurrences(c.Root.Regions, t, make(map[*Asset]int), 0)))
+			for _, a := range c.Candidates(t) {
+				q := quotas[t][a]
+				if q == 0 || walkErr != nil {
+					continue
+				}
+				// Count the trees which place a in t, as those which do not, taken from all of them.
+				without, err := c.combinations(c.Root.Regions, make(map[*Asset]*big.Int), quotaPick{t, a}, 0)
+				if err != nil {
+					walkErr = err
+					return
+				}
+				n := new(big.Int).Sub(total, without)
+				if new(big.Int).Mul(n, occurrences).Cmp(big.NewInt(int64(q))) < 0 {
+					errs = append(errs, c.errorf(t, "Trait %s is dealt to a region of kinds %v %d times, but only %s distinct pieces can place it there.", describe(a), t.Kinds, q, n))
+				}
+			}
+		}
+	})
+	return errs, walkErr
+}
+
+// occurrences returns the greatest number of times a configured region, target, may be placed in a single branch built from templates, memoizing the count for each asset in memo.
+func (c *Configuration) occurrences(templates []*Region, target *Region, memo map[*Asset]int, depth int) int {
+	if depth > maxDepth {
+		return 0
+	}
+	var n int
+	for _, t := range templates {
+		if t == target {
+			n++
+		}
+		var most int
+		for _, a := range c.Candidates(t) {
+			if a.Weight <= 0 {
+				continue
+			}
+			m, ok := memo[a]
+			if !ok {
+				m = c.occurrences(a.Regions, target, memo, depth+1)
+				memo[a] = m
+			}
+			if m > most {
+				most = m
+			}
+		}
+		n += most
+	}
+	return n
+}
+
+// maxRepairLogs is the number of pieces broken by repairs which are logged one by one, before the rest are summarized.
+const maxRepairLogs = 10
+
+// quotaPicker deals out assets from what remains of their quotas, picking by remaining count, so that every quota is used up exactly by the end of the supply. Conditions on the traits already picked scale the remaining counts, steering which pieces get a trait without changing how many do. Picks are held for the piece being built until committed, and returned if the piece is rebuilt.
+type quotaPicker struct {
+	rand      *rand.Rand
+	remaining map[*Region]map[*Asset]int
+	held      []quotaPick
+	loose     bool // Whether to deal from any of a region's candidates, once those the rules allow are used up.
+}
+
+// quotaPick is an asset taken from a region's quota.
+type quotaPick struct {
+	region *Region
+	asset  *Asset
+}
+
+func newQuotaPicker(quotas map[*Region]map[*Asset]int) *quotaPicker {
+	return &quotaPicker{
+		remaining: quotas,
+		held:      make([]quotaPick, 0),
+	}
+}
+
+func (qp *quotaPicker) pick(c *Configuration, t *Region, picked, candidates []*Asset) (*Asset, error) {
+	counts, ok := qp.remaining[t]
+	if !ok {
+		// Nothing was allotted to this region. Leave it empty.
+		return nil, nil
+	}
+	factors, conditional := c.conditionalWeights(picked, candidates)
+	weights := make([]float64, len(candidates))
+	var sum float64
+	for i, a := range candidates {
+		if a.Weight > 0 {
+			weights[i] = float64(counts[a])
+			// Scale by the conditions' factors alone, as the remaining count already accounts for the asset's own weight.
+			if conditional {
+				weights[i] *= factors[a] / a.Weight
+			}
+		}
+		sum += weights[i]
+	}
+	if sum == 0 && qp.loose {
+		// Deal what remains, whatever the rules say.
+		candidates = c.Candidates(t)
+		weights = make([]float64, len(candidates))
+		for i, a := range candidates {
+			weights[i] = float64(counts[a])
+			sum += weights[i]
+		}
+	}
+	if sum == 0 {
+		return nil, retryError{fmt.Errorf("The quotas for a region of kinds %v are used up, for the traits allowed alongside those already picked.", t.Kinds)}
+	}
+	a := candidates[pickIndex(weights, qp.rand.Float64())]
+	counts[a]--
+	qp.held = append(qp.held, quotaPick{t, a})
+	return a, nil
+}
+
+// reset returns the picks held for the piece being built to their quotas.
+func (qp *quotaPicker) reset() {
+	for _, h := range qp.held {
+		qp.remaining[h.region][h.asset]++
+	}
+	qp.held = qp.held[:0]
+}
+
+// commit keeps the picks held for the piece being built.
+func (qp *quotaPicker) commit() {
+	qp.held = qp.held[:0]
+}
+
+// left returns the total number of picks remaining.
+func (qp *quotaPicker) left() int {
+	var n int
+	for _, counts := range qp.remaining {
+		for _, count := range counts {
+			n += count
+		}
+	}
+	return n
+}
+
+/*
+allocate builds a piece for each of ids, dealing out traits from exact quotas. Dealing from quotas leaves less and less choice toward the end of the supply, so rather than rebuilding pieces which duplicate others, or which the remaining quotas can not make satisfy the rules, allocate repairs them afterward: branches of the composition tree are swapped between such a piece and another, at random, until both are unique and satisfy the rules. Swapping moves traits between pieces without changing how many of each are dealt, so every quota is kept exactly. Returns the pieces, or an error if any could not be built or made unique.
+*/
+func (g *CollectionGenerator) allocate(ids []uint) ([]*Piece, error) {
+	quotas, err := g.Config.Quotas(uint(len(ids)))
+	if err != nil {
+		return nil, err
+	}
+	// Catch quotas which could only be met by duplicating pieces, rather than searching for swaps which don't exist.
+	errs, err := g.Config.checkQuotas(quotas)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("The quotas for %d pieces can not all be met by unique pieces:\n%s", len(ids), errs)
+	}
+	qp := newQuotaPicker(quotas)
+	pieces := make([]*Piece, 0, len(ids))
+	for _, id := range ids {
+		p := g.newPiece(id)
+		qp.rand = rand.New(rand.NewSource(PieceSeed(g.Seed, id)))
+		if err := g.deal(p, qp); err != nil {
+			return nil, fmt.Errorf("%s %d of the %d requested were built.", err, len(pieces), len(ids))
+		}
+		qp.commit()
+		pieces = append(pieces, p)
+	}
+	if qp.left() > 0 {
+		logErr.Printf("%d trait quota picks were left over.\n", qp.left())
+	}
+	// Find the pieces in need of repair.
+	seen := make(map[string]*Piece)
+	broken := make([]*Piece, 0)
+	for _, p := range pieces {
+		if err := g.Config.Satisfied(p.Traits); err != nil {
+			log.Printf("Piece #%d breaks a rule, as dealt from the remaining quotas. %s Swapping traits to repair it.\n", p.Id, err)
+			broken = append(broken, p)
+			continue
+		}
+		hash := DNAHash(p.DNA)
+		if dup, ok := seen[hash]; ok {
+			log.Printf("Piece #%d duplicates piece #%d (DNA %q). Swapping traits to repair it.\n", p.Id, dup.Id, p.DNA)
+			broken = append(broken, p)
+			continue
+		}
+		seen[hash] = p
+	}
+	if err := g.repair(pieces, broken, seen); err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+// deal builds a piece, p, from what remains of the quotas, rebuilding it while it breaks a rule. If the remaining quotas can not satisfy the rules, the piece is dealt from them anyway, for allocate to repair. Returns an error if the piece could not be built at all.
+func (g *CollectionGenerator) deal(p *Piece, qp *quotaPicker) error {
+	for attempt := 1; attempt <= maxRuleAttempts; attempt++ {
+		err := p.grow(g.Config, qp)
+		if _, retry := err.(retryError); retry {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if g.Config.Satisfied(p.Traits) == nil {
+			return nil
+		}
+	}
+	qp.loose = true
+	defer func() {
+		qp.loose = false
+	}()
+	if err := p.grow(g.Config, qp); err != nil {
+		return fmt.Errorf("Failed to build piece #%d: %s", p.Id, err)
+	}
+	return nil
+}
+
+/*
+repair repairs each of the broken pieces in turn, by swapping a branch of its composition tree with the same branch of another of pieces, chosen at random, until it is unique and satisfies the rules. The DNA hashes of pieces known to be sound are kept in seen.
+
+A swap which repairs one piece may break the other. Rather than reject such a swap, the other piece is queued for repair in turn, which lets repairs work their way out of spots where no single swap would do. Returns an error if the pieces are not all repaired within MaxAttempts swaps per piece.
+*/
+func (g *CollectionGenerator) repair(pieces, broken []*Piece, seen map[string]*Piece) error {
+	c := g.Config
+	// sound reports whether a piece is unique among those known to be sound, besides itself, and satisfies the rules.
+	sound := func(p *Piece) bool {
+		owner, ok := seen[DNAHash(p.DNA)]
+		return (!ok || owner == p) && c.Satisfied(p.Traits) == nil
+	}
+	rnd := rand.New(rand.NewSource(g.Seed))
+	budget := g.MaxAttempts * uint(len(pieces))
+	// Count the pieces broken by repairs, so as not to log every one.
+	var rebroken int
+	for attempt := uint(1); len(broken) > 0; attempt++ {
+		p := broken[0]
+		// An earlier repair may already have fixed p.
+		if sound(p) {
+			seen[DNAHash(p.DNA)] = p
+			broken = broken[1:]
+			continue
+		}
+		if attempt > budget {
+			return fmt.Errorf("Could not make piece #%d unique, and satisfy the rules, in %d attempts, by swapping traits with other pieces. %d pieces were broken by repairs along the way.", p.Id, budget, rebroken)
+		}
+		q := pieces[rnd.Intn(len(pieces))]
+		if q == p || len(p.Asset.Regions) == 0 {
+			continue
+		}
+		i := rnd.Intn(len(p.Asset.Regions))
+		qHash := DNAHash(q.DNA)
+		swapBranches(p.Asset.Regions[i], q.Asset.Regions[i])
+		p.retrace(c)
+		q.retrace(c)
+		if seen[qHash] == q {
+			delete(seen, qHash)
+		}
+		if !sound(p) || DNAHash(p.DNA) == DNAHash(q.DNA) {
+			// Swap back.
+			swapBranches(p.Asset.Regions[i], q.Asset.Regions[i])
+			p.retrace(c)
+			q.retrace(c)
+			if sound(q) {
+				seen[qHash] = q
+			}
+			continue
+		}
+		seen[DNAHash(p.DNA)] = p
+		broken = broken[1:]
+		if sound(q) {
+			seen[DNAHash(q.DNA)] = q
+		} else {
+			if rebroken++; rebroken <= maxRepairLogs {
+				log.Printf("Piece #%d was broken repairing piece #%d. Swapping traits to repair it in turn.\n", q.Id, p.Id)
+			}
+			broken = append(broken, q)
+		}
+	}
+	if rebroken > maxRepairLogs {
+		log.Printf("%d more pieces were broken repairing others, and repaired in turn.\n", rebroken-maxRepairLogs)
+	}
+	return nil
+}
+
+// swapBranches swaps the assets, and so the branches of the composition tree, placed in two regions, a and b.
+func swapBranches(a, b *Region) {
+	a.Asset, b.Asset = b.Asset, a.Asset
+	if a.Asset != nil {
+		a.Asset.Parent = a
+	}
+	if b.Asset != nil {
+		b.Asset.Parent = b
+	}
+}
@@ -0,0 +1,158 @@
+package artwork
+
+import (
+	"image"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestApportion(t *testing.T) {
+	for _, test := range []struct {
+		weights []float64
+		n       int
+		want    []int
+	}{
+		{[]float64{0.5, 0.3, 0.2}, 4, []int{2, 1, 1}},
+		{[]float64{0.5, 0.3, 0.2}, 10, []int{5, 3, 2}},
+		// Ties go to the earlier weight.
+		{[]float64{1, 1, 1}, 4, []int{2, 1, 1}},
+		{[]float64{2, 1, 1}, 8, []int{4, 2, 2}},
+		// Nothing is given to weights of zero or less.
+		{[]float64{1, 0, -1}, 3, []int{3, 0, 0}},
+		{[]float64{0, 0}, 3, []int{0, 0}},
+		{[]float64{1, 1}, 0, []int{0, 0}},
+	} {
+		if got := Apportion(test.weights, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Apportion(%v, %d) = %v, expected %v.", test.weights, test.n, got, test.want)
+		}
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	c := testConfig()
+	quotas, err := c.Quotas(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crown := testAsset(c, "hat", "Crown")
+	for _, test := range []struct {
+		region *Region
+		kind   string
+		name   string
+		want   int
+	}{
+		{c.Root.Regions[0], "background", "Red", 4},
+		{c.Root.Regions[0], "background", "Blue", 4},
+		{c.Root.Regions[1], "hat", "Cap", 6},
+		{c.Root.Regions[1], "hat", "Crown", 2},
+		// The jewel is placed as often as the crown.
+		{crown.Regions[0], "jewel", "Ruby", 2},
+	} {
+		if got := quotas[test.region][testAsset(c, test.kind, test.name)]; got != test.want {
+			t.Errorf("%s/%s has a quota of %d, expected %d.", test.kind, test.name, got, test.want)
+		}
+	}
+}
+
+// traitCounts returns the number of times each trait was picked across pieces.
+func traitCounts(pieces []*Piece) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range pieces {
+		for _, name := range traitNames(p) {
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+func TestAllocate(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		g := NewCollectionGenerator(testConfig(), WithSupply(3), WithOutDir(t.TempDir()), WithSeed(seed), WithAllocation(Quota))
+		if _, err := g.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]int{"background/Red": 2, "background/Blue": 1, "hat/Cap": 2, "hat/Crown": 1, "jewel/Ruby": 1}
+		if got := traitCounts(g.Pieces); !reflect.DeepEqual(got, want) {
+			t.Errorf("With seed %d, traits were dealt %v, expected %v.", seed, got, want)
+		}
+		seen := make(map[string]bool)
+		for _, p := range g.Pieces {
+			if seen[p.DNA] {
+				t.Errorf("With seed %d, piece #%d duplicates another.", seed, p.Id)
+			}
+			seen[p.DNA] = true
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	c := testConfig()
+	g := NewCollectionGenerator(c, WithSeed(1))
+	pieces := make([]*Piece, 0, 3)
+	for i, dna := range []string{"0.2", "0.2", "1.3.4"} {
+		p := NewPiece(uint(i+1), nil, nil)
+		if err := p.BuildDNA(c, dna); err != nil {
+			t.Fatal(err)
+		}
+		pieces = append(pieces, p)
+	}
+	before := traitCounts(pieces)
+	seen := map[string]*Piece{DNAHash(pieces[0].DNA): pieces[0], DNAHash(pieces[2].DNA): pieces[2]}
+	if err := g.repair(pieces, pieces[1:2], seen); err != nil {
+		t.Fatal(err)
+	}
+	dnas := make([]string, len(pieces))
+	for i, p := range pieces {
+		dnas[i] = p.DNA
+	}
+	sort.Strings(dnas)
+	for i := 1; i < len(dnas); i++ {
+		if dnas[i] == dnas[i-1] {
+			t.Errorf("Repaired pieces still include a duplicate: %v.", dnas)
+		}
+	}
+	if after := traitCounts(pieces); !reflect.DeepEqual(after, before) {
+		t.Errorf("Repair changed the traits dealt, from %v to %v.", before, after)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	// A background dealt to 4 of 8 pieces, against 3 hats, must repeat a pairing.
+	blank := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	c := NewConfiguration()
+	c.Root = &Asset{Image: blank}
+	c.Root.Regions = []*Region{{Kinds: []string{"background"}}, {Kinds: []string{"hat"}}}
+	for _, name := range []string{"Red#2", "Blue#1", "Green#1", "Cap#1", "Crown#1", "Fez#1"} {
+		kind := "background"
+		if len(c.Assets) >= 3 {
+			kind = "hat"
+		}
+		name, weight, err := ParseAssetName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Assets = append(c.Assets, &Asset{Kind: kind, Name: name, Weight: weight, Image: blank})
+	}
+	g := NewCollectionGenerator(c, WithSupply(8), WithOutDir(t.TempDir()), WithAllocation(Quota))
+	_, err := g.Generate()
+	if err == nil || !strings.Contains(err.Error(), "Trait background/Red is dealt to a region of kinds [background] 4 times, but only 3 distinct pieces can place it there.") {
+		t.Errorf("Expected quotas which must duplicate pieces to fail up front, got %v.", err)
+	}
+	// With 4 hats, they fit.
+	c.Assets = append(c.Assets, &Asset{Kind: "hat", Name: "Beret", Weight: 1, Image: blank})
+	if errs, err := c.checkQuotas(mustQuotas(t, c, 8)); err != nil || len(errs) > 0 {
+		t.Errorf("Expected quotas to fit 4 hats, got %v, %v.", errs, err)
+	}
+}
+
+// mustQuotas returns the quotas of c for a supply, failing the test if they can not be computed.
+func mustQuotas(t *testing.T, c *Configuration, supply uint) map[*Region]map[*Asset]int {
+	t.Helper()
+	quotas, err := c.Quotas(supply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return quotas
+}
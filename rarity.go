@@ -0,0 +1,242 @@
+package artwork
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TraitCountType is the trait type under which TraitCountNormalized scores the number of traits each piece has, as though it were a trait of its own.
+const TraitCountType = "Trait Count"
+
+// RarityMethod is a method of scoring the rarity of a piece from the frequencies of its traits across a collection.
+type RarityMethod int
+
+const (
+	// StatisticalRarity scores a piece by the product of its traits' frequencies: the chance of a piece with its traits turning up at random. Unlike the other methods, lower scores are rarer.
+	StatisticalRarity RarityMethod = iota
+	// TraitRaritySum scores a piece by the sum of its traits' rarities, where a trait's rarity is the inverse of its frequency.
+	TraitRaritySum
+	// InformationContent scores a piece by the information content of its traits, the sum of -log2 of their frequencies, divided by the entropy of the collection's traits, so that scores are comparable between collections.
+	InformationContent
+	// TraitCountNormalized scores a piece as TraitRaritySum does, but divides each trait's rarity by the number of values its trait type has, so that types with many values do not dominate. The number of traits a piece has is scored as a trait too, of type TraitCountType.
+	TraitCountNormalized
+)
+
+// rarityMethods maps the name of each rarity method to the method.
+var rarityMethods = map[string]RarityMethod{
+	"statistical":            StatisticalRarity,
+	"trait-rarity-sum":       TraitRaritySum,
+	"information-content":    InformationContent,
+	"trait-count-normalized": TraitCountNormalized,
+}
+
+// String returns the name of the rarity method.
+func (m RarityMethod) String() string {
+	for name, rm := range rarityMethods {
+		if rm == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("RarityMethod(%d)", int(m))
+}
+
+// ParseRarityMethod returns the rarity method with the given name, as returned by RarityMethod.String. Returns an error if there is no such method.
+func ParseRarityMethod(name string) (RarityMethod, error) {
+	if m, ok := rarityMethods[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	names := make([]string, 0, len(rarityMethods))
+	for n := range rarityMethods {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("Unknown rarity method %q. Expected one of: %s.", name, strings.Join(names, ", "))
+}
+
+// rarer reports whether score, a, is rarer than score, b, under the method.
+func (m RarityMethod) rarer(a, b float64) bool {
+	if m == StatisticalRarity {
+		return a < b
+	}
+	return a > b
+}
+
+// Rarity is the rarity of a piece, Id, with DNA, DNA: its Score under a RarityMethod, Method, and its Rank in the collection, where 1 is the rarest. Pieces with equal scores share a rank.
+type Rarity struct {
+	Id     uint         `json:"id"`
+	DNA    string       `json:"dna"`
+	Method RarityMethod `json:"-"`
+	Score  float64      `json:"score"`
+	Rank   int          `json:"rank"`
+}
+
+// Rarities is a ranked list of rarities, from rarest to most common.
+type Rarities []*Rarity
+
+/*
+Frequencies computes the frequency of each trait across pieces: the share of the pieces with the trait, from 0.0 to 1.0. A trait is counted once per piece, however many times it appears. Returns the frequencies as an AttributeWeightMap.
+*/
+func Frequencies(pieces []*Piece) AttributeWeightMap {
+	m := make(AttributeWeightMap)
+	if len(pieces) == 0 {
+		return m
+	}
+	for _, p := range pieces {
+		seen := make(map[Attribute]bool)
+		for _, attr := range p.Attributes() {
+			if seen[attr] {
+				continue
+			}
+			seen[attr] = true
+			m[attr]++
+		}
+	}
+	for attr := range m {
+		m[attr] /= float64(len(pieces))
+	}
+	return m
+}
+
+// rarityTraits returns each piece's traits, as scored for rarity: hidden traits are left out, and a piece without a trait of some type found elsewhere in the collection is given a trait of that type with an empty value, so that lacking a trait counts toward rarity as having one does. If counted, each piece is also given a TraitCountType trait.
+func rarityTraits(pieces []*Piece, counted bool) map[*Piece][]Attribute {
+	types := make(map[string]bool)
+	traits := make(map[*Piece][]Attribute, len(pieces))
+	for _, p := range pieces {
+		attrs := make([]Attribute, 0, len(p.Traits))
+		for _, attr := range p.Attributes() {
+			if !attr.Hidden {
+				attrs = append(attrs, attr)
+				types[attr.TraitType] = true
+			}
+		}
+		traits[p] = attrs
+	}
+	// Add missing types in a stable order, so that scores are always summed the same way.
+	sorted := make([]string, 0, len(types))
+	for t := range types {
+		sorted = append(sorted, t)
+	}
+	sort.Strings(sorted)
+	for _, p := range pieces {
+		has := make(map[string]bool)
+		for _, attr := range traits[p] {
+			has[attr.TraitType] = true
+		}
+		n := len(has)
+		for _, t := range sorted {
+			if !has[t] {
+				traits[p] = append(traits[p], Attribute{TraitType: t})
+			}
+		}
+		if counted {
+			traits[p] = append(traits[p], Attribute{TraitType: TraitCountType, Value: strconv.Itoa(n)})
+		}
+	}
+	return traits
+}
+
+/*
+Rank scores the rarity of each of pieces with method, from the frequencies of their traits across pieces, and ranks them from rarest to most common. Each piece's Rarity is set. Returns the Rarities, in rank order; pieces of equal rank are ordered by Id.
+*/
+func Rank(pieces []*Piece, method RarityMethod) Rarities {
+	traits := rarityTraits(pieces, method == TraitCountNormalized)
+	// Count each trait, once per piece, and the values of each trait type.
+	freq := make(AttributeWeightMap)
+	values := make(map[string]int)
+	for _, p := range pieces {
+		seen := make(map[Attribute]bool)
+		for _, attr := range traits[p] {
+			if seen[attr] {
+				continue
+			}
+			seen[attr] = true
+			if freq[attr] == 0 {
+				values[attr.TraitType]++
+			}
+			freq[attr]++
+		}
+	}
+	for attr := range freq {
+		freq[attr] /= float64(len(pieces))
+	}
+	// The entropy of the collection's traits, for normalizing information content.
+	var entropy float64
+	for _, attr := range freq.Items() {
+		entropy -= freq[attr] * math.Log2(freq[attr])
+	}
+	rarities := make(Rarities, 0, len(pieces))
+	for _, p := range pieces {
+		r := &Rarity{Id: p.Id, DNA: p.DNA, Method: method}
+		if method == StatisticalRarity {
+			r.Score = 1
+		}
+		for _, attr := range traits[p] {
+			f := freq[attr]
+			switch method {
+			case StatisticalRarity:
+				r.Score *= f
+			case TraitRaritySum:
+				r.Score += 1 / f
+			case InformationContent:
+				r.Score -= math.Log2(f)
+			case TraitCountNormalized:
+				r.Score += 1 / f / float64(values[attr.TraitType])
+			}
+		}
+		if method == InformationContent && entropy > 0 {
+			r.Score /= entropy
+		}
+		p.Rarity = r
+		rarities = append(rarities, r)
+	}
+	sort.SliceStable(rarities, func(i, j int) bool {
+		a, b := rarities[i], rarities[j]
+		if a.Score != b.Score {
+			return method.rarer(a.Score, b.Score)
+		}
+		return a.Id < b.Id
+	})
+	for i, r := range rarities {
+		r.Rank = i + 1
+		if i > 0 && r.Score == rarities[i-1].Score {
+			r.Rank = rarities[i-1].Rank
+		}
+	}
+	return rarities
+}
+
+// WriteCSV writes the rarities to w as CSV, with a header row, and a row for each piece giving its rank, id, score and DNA.
+func (rs Rarities) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "id", "score", "dna"})
+	for _, r := range rs {
+		cw.Write([]string{
+			strconv.Itoa(r.Rank),
+			strconv.FormatUint(uint64(r.Id), 10),
+			strconv.FormatFloat(r.Score, 'g', -1, 64),
+			r.DNA,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the rarities to w as a JSON object, naming the rarity method, with a list of pieces giving each one's rank, id, score and DNA.
+func (rs Rarities) WriteJSON(w io.Writer) error {
+	var method string
+	if len(rs) > 0 {
+		method = rs[0].Method.String()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(struct {
+		Method string   `json:"method"`
+		Pieces Rarities `json:"pieces"`
+	}{method, rs})
+}
@@ -0,0 +1,54 @@
+package artwork
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// rarityPieces returns four pieces, where piece 4 alone has a crown, and piece 3 alone has no hat.
+func rarityPieces() []*Piece {
+	red := &Asset{Kind: "background", Name: "Red"}
+	blue := &Asset{Kind: "background", Name: "Blue"}
+	cap := &Asset{Kind: "hat", Name: "Cap"}
+	crown := &Asset{Kind: "hat", Name: "Crown"}
+	return []*Piece{
+		{Id: 1, Traits: []*Asset{red, cap}},
+		{Id: 2, Traits: []*Asset{blue, cap}},
+		{Id: 3, Traits: []*Asset{red}},
+		{Id: 4, Traits: []*Asset{blue, crown}},
+	}
+}
+
+func TestRank(t *testing.T) {
+	for _, m := range []RarityMethod{StatisticalRarity, TraitRaritySum, InformationContent} {
+		pieces := rarityPieces()
+		rs := Rank(pieces, m)
+		if len(rs) != len(pieces) {
+			t.Fatalf("%s: expected %d rarities, got %d.", m, len(pieces), len(rs))
+		}
+		// Pieces 3 and 4 each have a trait, or lack of one, found on no other piece, and are equally rare.
+		if rs[0].Rank != 1 || rs[1].Rank != 1 || rs[0].Id != 3 || rs[1].Id != 4 {
+			t.Errorf("%s: expected pieces 3 and 4 to share rank 1, got %+v and %+v.", m, *rs[0], *rs[1])
+		}
+		if rs[2].Rank != 3 || rs[3].Rank != 3 {
+			t.Errorf("%s: expected pieces 1 and 2 to share rank 3, got %+v and %+v.", m, *rs[2], *rs[3])
+		}
+		if pieces[0].Rarity == nil || pieces[0].Rarity.Rank != 3 {
+			t.Errorf("%s: expected piece 1's rarity to be set.", m)
+		}
+	}
+	// Counting traits sets piece 3, the only piece with a single trait, apart.
+	rs := Rank(rarityPieces(), TraitCountNormalized)
+	if rs[0].Id != 3 || rs[0].Rank != 1 || rs[1].Id != 4 || rs[1].Rank != 2 {
+		t.Errorf("%s: expected pieces 3 and 4 to rank 1 and 2, got %+v and %+v.", TraitCountNormalized, *rs[0], *rs[1])
+	}
+	var csv bytes.Buffer
+	if err := Rank(rarityPieces(), TraitRaritySum).WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 5 || lines[0] != "rank,id,score,dna" || lines[1] != "1,3,6," {
+		t.Errorf("Unexpected CSV:\n%s", csv.String())
+	}
+}
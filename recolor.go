@@ -0,0 +1,238 @@
+package artwork
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RecolorMode is a mode by which a Palette recolors an image.
+type RecolorMode int
+
+const (
+	// RecolorExact replaces each of the palette's Source colors with the Target color at the same index, wherever it appears exactly.
+	RecolorExact RecolorMode = iota
+	// RecolorHueShift rotates the hue of the palette's Source colors, or of every color if there are none, by Hue degrees, keeping their saturation and lightness.
+	RecolorHueShift
+)
+
+// recolorModes maps the name of each recolor mode to the mode.
+var recolorModes = map[string]RecolorMode{
+	"exact":     RecolorExact,
+	"hue-shift": RecolorHueShift,
+}
+
+// String returns the name of the recolor mode.
+func (m RecolorMode) String() string {
+	for name, rm := range recolorModes {
+		if rm == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("RecolorMode(%d)", int(m))
+}
+
+// ParseRecolorMode returns the recolor mode with the given name, as returned by RecolorMode.String. Returns an error if there is no such mode.
+func ParseRecolorMode(name string) (RecolorMode, error) {
+	if m, ok := recolorModes[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	names := make([]string, 0, len(recolorModes))
+	for n := range recolorModes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("Unknown recolor mode %q. Expected one of: %s.", name, strings.Join(names, ", "))
+}
+
+/*
+Palette recolors an asset's image at render time, by a recolor mode, Mode, so that one image may give many trait variants. Source colors are matched by red, green and blue alone, so that anti-aliased edges of a color are recolored along with it. Under RecolorExact, a matched pixel takes its Target color, with the pixel's alpha scaled by the target's. Under RecolorHueShift, its hue is rotated by Hue degrees.
+
+Images an asset loaded from a file are recolored once, and cached by path. Others, such as those rendered for each piece, are recolored each time, as they would otherwise be kept for as long as the palette.
+*/
+type Palette struct {
+	Mode   RecolorMode
+	Source []color.NRGBA
+	Target []color.NRGBA
+	Hue    float64
+	mu     sync.Mutex
+	cache  map[string]*image.NRGBA
+}
+
+// recolorFile returns img, loaded from a file, path, recolored by the palette. The result is cached, and returned again for the same path.
+func (p *Palette) recolorFile(path string, img image.Image) *image.NRGBA {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if recolored, ok := p.cache[path]; ok {
+		return recolored
+	}
+	if p.cache == nil {
+		p.cache = make(map[string]*image.NRGBA)
+	}
+	recolored := p.Recolor(img)
+	p.cache[path] = recolored
+	return recolored
+}
+
+// Recolor returns a new image, img, recolored by the palette.
+func (p *Palette) Recolor(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	recolored := image.NewNRGBA(bounds)
+	draw.Draw(recolored, bounds, img, bounds.Min, draw.Src)
+	// Index the source colors, by red, green and blue.
+	index := make(map[[3]uint8]int, len(p.Source))
+	for i, c := range p.Source {
+		index[[3]uint8{c.R, c.G, c.B}] = i
+	}
+	// Colors repeat, so remember each one's recoloring.
+	shifted := make(map[[3]uint8][3]uint8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := recolored.NRGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+			rgb := [3]uint8{c.R, c.G, c.B}
+			i, ok := index[rgb]
+			switch p.Mode {
+			case RecolorExact:
+				if !ok {
+					continue
+				}
+				t := p.Target[i]
+				c = color.NRGBA{t.R, t.G, t.B, uint8((int(c.A)*int(t.A) + 0x7f) / 0xff)}
+			case RecolorHueShift:
+				if !ok && len(p.Source) > 0 {
+					continue
+				}
+				s, ok := shifted[rgb]
+				if !ok {
+					s = shiftHue(rgb, p.Hue)
+					shifted[rgb] = s
+				}
+				c.R, c.G, c.B = s[0], s[1], s[2]
+			}
+			recolored.SetNRGBA(x, y, c)
+		}
+	}
+	return recolored
+}
+
+// check checks the palette: exact palettes must map each source color to a target, and hue shifts must be finite. Returns an error describing the first problem found, or nil.
+func (p *Palette) check() error {
+	switch p.Mode {
+	case RecolorExact:
+		if len(p.Source) == 0 {
+			return fmt.Errorf("Palette has no source colors to replace.")
+		}
+		if len(p.Target) != len(p.Source) {
+			return fmt.Errorf("Palette has %d source colors, but %d target colors.", len(p.Source), len(p.Target))
+		}
+	case RecolorHueShift:
+		if !finite(p.Hue) {
+			return fmt.Errorf("Palette hue shift, %v, is not finite.", p.Hue)
+		}
+	default:
+		return fmt.Errorf("Palette has an unknown recolor mode, %s.", p.Mode)
+	}
+	return nil
+}
+
+// shiftHue rotates the hue of a color, rgb, by deg degrees, keeping its saturation and lightness. Returns the shifted color.
+func shiftHue(rgb [3]uint8, deg float64) [3]uint8 {
+	r, g, b := float64(rgb[0])/0xff, float64(rgb[1])/0xff, float64(rgb[2])/0xff
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	// Grays have no hue to shift.
+	if max == min {
+		return rgb
+	}
+	d := max - min
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h = math.Mod(h*60+deg, 360)
+	if h < 0 {
+		h += 360
+	}
+	// Back to red, green and blue.
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var c [3]float64
+	switch {
+	case h < 60:
+		c = [3]float64{chroma, x, 0}
+	case h < 120:
+		c = [3]float64{x, chroma, 0}
+	case h < 180:
+		c = [3]float64{0, chroma, x}
+	case h < 240:
+		c = [3]float64{0, x, chroma}
+	case h < 300:
+		c = [3]float64{x, 0, chroma}
+	default:
+		c = [3]float64{chroma, 0, x}
+	}
+	m := l - chroma/2
+	var out [3]uint8
+	for i, v := range c {
+		out[i] = uint8(math.Round(math.Max(0, math.Min(1, v+m)) * 0xff))
+	}
+	return out
+}
+
+// ParseColor parses a color written in hex, as "#rrggbb", or "#rrggbbaa", with the leading "#" optional. Returns the color.NRGBA, or an error if s is not such a color.
+func ParseColor(s string) (color.NRGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || (len(b) != 3 && len(b) != 4) {
+		return color.NRGBA{}, fmt.Errorf("Invalid color %q. Expected hex, as \"#rrggbb\" or \"#rrggbbaa\".", s)
+	}
+	c := color.NRGBA{b[0], b[1], b[2], 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+// Variant returns a variant of the asset, a new asset of the same kind and image, named name and weighted weight, whose image is recolored by a palette, p, at render time, along with those of its parts. Its regions and parts are copies of the asset's. Add it to a Configuration's Assets to make it a trait value of its own.
+func (a *Asset) Variant(name string, weight float64, p *Palette) *Asset {
+	v := a.clone()
+	v.origin = nil
+	v.Name, v.Weight, v.Palette = name, weight, p
+	for _, part := range v.Parts {
+		part.template = nil
+		if part.Asset != nil {
+			part.Asset.origin, part.Asset.Palette = nil, p
+		}
+	}
+	for _, r := range a.Regions {
+		cr := r.clone()
+		cr.template = nil
+		v.Regions = append(v.Regions, cr)
+	}
+	return v
+}
+
+// image returns the asset's image, recolored by its palette, if it has one. Only images loaded from a file are cached by the palette.
+func (a *Asset) image() image.Image {
+	if a.Palette == nil || a.Image == nil {
+		return a.Image
+	}
+	if a.Path == "" || a.Text != nil {
+		return a.Palette.Recolor(a.Image)
+	}
+	return a.Palette.recolorFile(a.Path, a.Image)
+}
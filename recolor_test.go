@@ -0,0 +1,41 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPaletteRecolor(t *testing.T) {
+	red, dark := color.NRGBA{204, 0, 0, 255}, color.NRGBA{136, 0, 0, 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, red)
+	// Anti-aliased edges keep their alpha.
+	img.SetNRGBA(1, 0, color.NRGBA{204, 0, 0, 128})
+	img.SetNRGBA(2, 0, dark)
+
+	exact := &Palette{Mode: RecolorExact, Source: []color.NRGBA{red}, Target: []color.NRGBA{{0, 0, 204, 255}}}
+	recolored := exact.Recolor(img)
+	for x, want := range []color.NRGBA{{0, 0, 204, 255}, {0, 0, 204, 128}, dark} {
+		if got := recolored.NRGBAAt(x, 0); got != want {
+			t.Errorf("Exact recolor of pixel %d gave %v, expected %v.", x, got, want)
+		}
+	}
+	// Images loaded from a file are cached by path, and others recolored afresh.
+	loaded := &Asset{Path: "red.png", Image: img, Palette: exact}
+	if loaded.image() != loaded.image() || len(exact.cache) != 1 {
+		t.Errorf("Recoloring a loaded image again should return the cached image.")
+	}
+	rendered := &Asset{Image: img, Palette: exact}
+	if rendered.image() == rendered.image() || len(exact.cache) != 1 {
+		t.Errorf("Recoloring an image not loaded from a file should not cache it.")
+	}
+
+	hue := &Palette{Mode: RecolorHueShift, Hue: 120}
+	recolored = hue.Recolor(img)
+	for x, want := range []color.NRGBA{{0, 204, 0, 255}, {0, 204, 0, 128}, {0, 136, 0, 255}} {
+		if got := recolored.NRGBAAt(x, 0); got != want {
+			t.Errorf("Hue shift of pixel %d gave %v, expected %v.", x, got, want)
+		}
+	}
+}
@@ -1,14 +1,35 @@
 package artwork
 
-import "image"
+import (
+	"image"
+	"math"
 
-// Region defines an overlay region, with center-point coordinates, Coords, a slice of applicable kinds, Kinds, and an asset to overlay, Asset.
+	"golang.org/x/image/math/f64"
+)
+
+// Region defines an overlay region, with center-point coordinates, Coords, a slice of applicable kinds, Kinds, and an asset to overlay, Asset. The asset, with everything composited onto it, is placed, transformed and blended with what lies beneath as its fields describe.
 type Region struct {
 	*Asset
-	Coords *image.Point
-	Kinds  []string
-	Scale  *Scale
-	//Transform f64.Aff3
+	Coords        *image.Point
+	Kinds         []string
+	Scale         *Scale        // Scales the asset, mirroring it where negative.
+	Transform     *Transform    // Transforms the asset about its center, after its Scale.
+	Blend         BlendMode     // How the asset is blended with what lies beneath.
+	Opacity       *float64      // In [0.0, 1.0], or fully opaque, if nil.
+	Anchor        *Anchor       // The point on the asset placed at Coords, if set; otherwise its Pivot, if it has one, or else its center.
+	Interpolation Interpolation // The kernel by which the asset is resampled when scaled or transformed.
+	PixelArt      bool          // Keeps the asset's pixels whole, whatever the Interpolation.
+	Effects       []Effect      // Applied to the asset, in order, before it is clipped and blended.
+	Clip          *Clip         // Clips the asset to a mask, if set.
+	Z             int           // Orders the asset among the rest of the tree, relative to its parent's: beneath it, where negative, or above everything of a lower Z.
+	template      *Region       // The configured region this was cloned from, if any.
+}
+
+// Transform is an affine transform, applied about the center of whatever is placed in a region, after its Scale. Rotate rotates it clockwise, by degrees, and SkewX and SkewY skew it along each axis, by degrees. Matrix, if set, is applied last. Its translation is ignored, as the region's coordinates place the result.
+type Transform struct {
+	Rotate       float64
+	SkewX, SkewY float64
+	Matrix       *f64.Aff3
 }
 
 func NewRegion() *Region {
@@ -17,11 +38,81 @@ func NewRegion() *Region {
 	}
 }
 
-// Coordinates is a getter function for region coordinates. Defaults to center.
-func (r *Region) Coordinates() *image.Point {
+// clone returns a copy of the region's configuration, without an asset.
+func (r *Region) clone() *Region {
+	return &Region{
+		Coords:        r.Coords,
+		Kinds:         r.Kinds,
+		Scale:         r.Scale,
+		Transform:     r.Transform,
+		Blend:         r.Blend,
+		Opacity:       r.Opacity,
+		Anchor:        r.Anchor,
+		Interpolation: r.Interpolation,
+		PixelArt:      r.PixelArt,
+		Effects:       r.Effects,
+		Clip:          r.Clip,
+		Z:             r.Z,
+		template:      r,
+	}
+}
+
+// Coordinates is a getter function for region coordinates. Defaults to the center of the parent asset's bounds, parent.
+func (r *Region) Coordinates(parent image.Rectangle) image.Point {
 	// Default to center.
 	if r.Coords == nil {
-		r.Coords = &image.Point{r.Bounds().Min.Add(r.Bounds().Dx() / 2), r.Bounds().Min.Add(r.Bounds().Dy() / 2)}
+		return parent.Min.Add(image.Point{parent.Dx() / 2, parent.Dy() / 2})
+	}
+	return *r.Coords
+}
+
+// opacity returns the opacity at which the region is composited.
+func (r *Region) opacity() float64 {
+	if r.Opacity == nil {
+		return 1
+	}
+	return *r.Opacity
+}
+
+// Matrix returns the linear part of the region's Scale and Transform, combined, as an f64.Aff3 with no translation. Scale factors of zero are taken as 1.0, leaving that axis unscaled.
+func (r *Region) Matrix() f64.Aff3 {
+	sx, sy := 1.0, 1.0
+	if r.Scale != nil {
+		if r.Scale.X != 0 {
+			sx = r.Scale.X
+		}
+		if r.Scale.Y != 0 {
+			sy = r.Scale.Y
+		}
+	}
+	m := f64.Aff3{sx, 0, 0, 0, sy, 0}
+	if t := r.Transform; t != nil {
+		kx, ky := math.Tan(t.SkewX*math.Pi/180), math.Tan(t.SkewY*math.Pi/180)
+		m = mulAff3(f64.Aff3{1, kx, 0, ky, 1, 0}, m)
+		sin, cos := math.Sincos(t.Rotate * math.Pi / 180)
+		// Keep quarter turns exact, so they move whole pixels.
+		if math.Mod(t.Rotate, 90) == 0 {
+			sin, cos = math.Round(sin), math.Round(cos)
+		}
+		m = mulAff3(f64.Aff3{cos, -sin, 0, sin, cos, 0}, m)
+		if t.Matrix != nil {
+			linear := *t.Matrix
+			linear[2], linear[5] = 0, 0
+			m = mulAff3(linear, m)
+		}
+	}
+	return m
+}
+
+// affine reports whether the region needs an affine transform to composite, rather than a plain, positive scale.
+func (r *Region) affine() bool {
+	return r.Transform != nil || (r.Scale != nil && (r.Scale.X < 0 || r.Scale.Y < 0))
+}
+
+// mulAff3 returns the product of two affine transforms, a and b, which applies b, then a.
+func mulAff3(a, b f64.Aff3) f64.Aff3 {
+	return f64.Aff3{
+		a[0]*b[0] + a[1]*b[3], a[0]*b[1] + a[1]*b[4], a[0]*b[2] + a[1]*b[5] + a[2],
+		a[3]*b[0] + a[4]*b[3], a[3]*b[1] + a[4]*b[4], a[3]*b[2] + a[4]*b[5] + a[5],
 	}
-	return r.Coords
 }
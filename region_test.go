@@ -0,0 +1,65 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/math/f64"
+)
+
+func TestMatrix(t *testing.T) {
+	for _, test := range []struct {
+		region Region
+		want   f64.Aff3
+		affine bool
+	}{
+		{Region{}, f64.Aff3{1, 0, 0, 0, 1, 0}, false},
+		// A scale factor of zero leaves its axis unscaled.
+		{Region{Scale: &Scale{2, 0}}, f64.Aff3{2, 0, 0, 0, 1, 0}, false},
+		{Region{Scale: &Scale{-1, 1}}, f64.Aff3{-1, 0, 0, 0, 1, 0}, true},
+		{Region{Transform: &Transform{Rotate: 90}}, f64.Aff3{0, -1, 0, 1, 0, 0}, true},
+		{Region{Transform: &Transform{SkewX: 45}}, f64.Aff3{1, 1, 0, 0, 1, 0}, true},
+		// The transform applies after the scale, and its matrix's translation is ignored.
+		{Region{Scale: &Scale{2, 1}, Transform: &Transform{Rotate: 90}}, f64.Aff3{0, -1, 0, 2, 0, 0}, true},
+		{Region{Transform: &Transform{Matrix: &f64.Aff3{1, 0, 5, 0, -1, 5}}}, f64.Aff3{1, 0, 0, 0, -1, 0}, true},
+	} {
+		m := test.region.Matrix()
+		for i := range m {
+			if math.Abs(m[i]-test.want[i]) > 1e-9 {
+				t.Errorf("Matrix of scale %v and transform %v is %v, expected %v.", test.region.Scale, test.region.Transform, m, test.want)
+				break
+			}
+		}
+		if a := test.region.affine(); a != test.affine {
+			t.Errorf("Region of scale %v and transform %v is affine: %v, expected %v.", test.region.Scale, test.region.Transform, a, test.affine)
+		}
+	}
+}
+
+func TestTransformImage(t *testing.T) {
+	// A 4x2 image, red on its left half.
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+	mirror := &Region{Scale: &Scale{-1, 1}}
+	flipped := TransformImage(src, mirror.Matrix(), nil)
+	if b := flipped.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Fatalf("Mirrored bounds are %v, expected 4x2.", b)
+	}
+	b := flipped.Bounds()
+	if c := flipped.NRGBAAt(b.Max.X-1, b.Min.Y); c.R != 255 || c.A != 255 {
+		t.Errorf("Mirrored image should be red on its right, got %v.", c)
+	}
+	if c := flipped.NRGBAAt(b.Min.X, b.Min.Y); c.A != 0 {
+		t.Errorf("Mirrored image should be clear on its left, got %v.", c)
+	}
+	rotate := &Region{Transform: &Transform{Rotate: 90}}
+	if b := TransformImage(src, rotate.Matrix(), nil).Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Errorf("Rotated bounds are %v, expected 2x4.", b)
+	}
+}
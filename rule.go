@@ -0,0 +1,280 @@
+package artwork
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxRuleAttempts limits how many times Build will rebuild a piece whose picks fail to satisfy the configured Rules.
+const maxRuleAttempts = 100
+
+// RuleType is the type of a Rule.
+type RuleType int
+
+const (
+	// Excludes rules prevent two traits from appearing together.
+	Excludes RuleType = iota
+	// Requires rules allow a trait to appear only if another trait also appears. Pieces which fail a requirement are rebuilt.
+	Requires
+	// Implies rules force a trait to be picked, wherever it may be, whenever another trait appears. A forced pairing is a pair of Implies rules, one in each direction.
+	Implies
+)
+
+// String returns the name of the rule type, as used in composition files.
+func (rt RuleType) String() string {
+	switch rt {
+	case Excludes:
+		return "excludes"
+	case Requires:
+		return "requires"
+	case Implies:
+		return "implies"
+	}
+	return fmt.Sprintf("RuleType(%d)", int(rt))
+}
+
+// TraitRef refers to traits by Kind and Name. An empty Name refers to every trait of the Kind.
+type TraitRef struct {
+	Kind string
+	Name string
+}
+
+// Matches reports whether a refers to an asset, a.
+func (t TraitRef) Matches(a *Asset) bool {
+	return a.Kind == t.Kind && (t.Name == "" || a.Name == t.Name)
+}
+
+// String returns the trait reference in the form "kind/name", or "kind/*" if it refers to every trait of its kind.
+func (t TraitRef) String() string {
+	if t.Name == "" {
+		return t.Kind + "/*"
+	}
+	return t.Kind + "/" + t.Name
+}
+
+// Rule is a compatibility rule between two traits, If and Then. For example, a Rule{Excludes, laserEyes, sunglasses} ensures laser eyes are never picked along with sunglasses.
+type Rule struct {
+	Type RuleType
+	If   TraitRef
+	Then TraitRef
+}
+
+// String describes the rule, as in "eyes/Laser excludes eyewear/Sunglasses".
+func (r *Rule) String() string {
+	return fmt.Sprintf("%s %s %s", r.If, r.Type, r.Then)
+}
+
+// describe returns a short description of an asset, for messages.
+func describe(a *Asset) string {
+	return TraitRef{a.Kind, a.Name}.String()
+}
+
+// excludes reports whether a and b may not appear together.
+func (c *Configuration) excludes(a, b *Asset) bool {
+	for _, r := range c.Rules {
+		if r.Type != Excludes {
+			continue
+		}
+		if (r.If.Matches(a) && r.Then.Matches(b)) || (r.If.Matches(b) && r.Then.Matches(a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matching returns the weighted assets to which a trait reference, t, refers.
+func (c *Configuration) matching(t TraitRef) []*Asset {
+	matches := make([]*Asset, 0)
+	for _, a := range c.Assets {
+		if a.Weight > 0 && t.Matches(a) {
+			matches = append(matches, a)
+		}
+	}
+	return matches
+}
+
+// constrain filters the candidates for a region against the traits already picked: candidates excluded by a picked trait, or which need a trait that has been excluded, are removed. If any remaining candidates are implied by a picked trait, only those are returned.
+func (c *Configuration) constrain(picked, candidates []*Asset) []*Asset {
+	if len(c.Rules) == 0 {
+		return candidates
+	}
+	allowed := make([]*Asset, 0, len(candidates))
+	for _, a := range candidates {
+		if !c.blocked(a, picked, make(map[*Asset]bool)) {
+			allowed = append(allowed, a)
+		}
+	}
+	// Force implied traits.
+	forced := make([]*Asset, 0)
+	for _, a := range allowed {
+		for _, r := range c.Rules {
+			if r.Type != Implies || !r.Then.Matches(a) {
+				continue
+			}
+			if containsMatch(picked, r.If) {
+				forced = append(forced, a)
+				break
+			}
+		}
+	}
+	if len(forced) > 0 {
+		return forced
+	}
+	return allowed
+}
+
+// blocked reports whether a may not be picked alongside the traits already picked. Assets being checked further up the chain of needs are tracked in visiting, and assumed possible.
+func (c *Configuration) blocked(a *Asset, picked []*Asset, visiting map[*Asset]bool) bool {
+	if visiting[a] {
+		return false
+	}
+	visiting[a] = true
+	defer delete(visiting, a)
+	for _, p := range picked {
+		if c.excludes(a, p) {
+			return true
+		}
+	}
+	// Check whether a needs something which can no longer appear.
+	for _, r := range c.Rules {
+		if r.Type == Excludes || !r.If.Matches(a) || containsMatch(picked, r.Then) {
+			continue
+		}
+		possible := false
+		for _, b := range c.matching(r.Then) {
+			if !c.excludes(a, b) && !c.blocked(b, picked, visiting) {
+				possible = true
+				break
+			}
+		}
+		if !possible {
+			return true
+		}
+	}
+	return false
+}
+
+// containsMatch reports whether any of assets is referred to by t.
+func containsMatch(assets []*Asset, t TraitRef) bool {
+	for _, a := range assets {
+		if t.Matches(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfied checks that a set of picked traits satisfies every rule. Returns an error describing the first rule broken, or nil.
+func (c *Configuration) Satisfied(picked []*Asset) error {
+	for _, r := range c.Rules {
+		for _, a := range picked {
+			if !r.If.Matches(a) {
+				continue
+			}
+			switch r.Type {
+			case Excludes:
+				if containsMatch(picked, r.Then) {
+					return fmt.Errorf("Rule broken: %s.", r)
+				}
+			case Requires, Implies:
+				if !containsMatch(picked, r.Then) {
+					return fmt.Errorf("Rule broken: %s.", r)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules statically checks the configured rules: each must refer to configured traits, and together they must not make any weighted trait impossible to pick. A trait is impossible if it needs, directly or through other rules, a trait which cannot be picked, can not be placed in any region, or is excluded by another of its needs.
+func (c *Configuration) checkRules() Errors {
+	var errs Errors
+	for _, r := range c.Rules {
+		if r.Type < Excludes || r.Type > Implies {
+			errs = append(errs, c.errorf(r, "Rule has an unknown type, %s.", r.Type))
+		}
+		for _, t := range []TraitRef{r.If, r.Then} {
+			if t.Kind == "" {
+				errs = append(errs, c.errorf(r, "Rule %q refers to a trait with no kind.", r))
+			} else if len(c.matching(t)) == 0 {
+				errs = append(errs, c.errorf(r, "Rule %q refers to %s, which matches no weighted asset.", r, t))
+			}
+		}
+	}
+	if len(errs) > 0 || len(c.Rules) == 0 {
+		return errs
+	}
+	// Gather the kinds which may be placed.
+	placeable := make(map[string]bool)
+	c.Walk(func(a *Asset) {
+		for _, r := range a.Regions {
+			for _, k := range r.Kinds {
+				placeable[k] = true
+			}
+		}
+	})
+	for _, a := range c.Assets {
+		if a.Weight <= 0 {
+			continue
+		}
+		if reason := c.impossible(a, placeable); reason != "" {
+			errs = append(errs, c.errorf(a, "Rules make trait %s impossible to pick: %s", describe(a), reason))
+		}
+	}
+	return errs
+}
+
+// impossible determines whether the rules make it impossible to pick a. Returns the reason, or an empty string if a may be picked.
+func (c *Configuration) impossible(a *Asset, placeable map[string]bool) string {
+	// Follow needs, as far as they lead to a single trait.
+	needs, because := []*Asset{a}, map[*Asset]string{}
+	for i := 0; i < len(needs); i++ {
+		n := needs[i]
+		for _, r := range c.Rules {
+			if r.Type == Excludes || !r.If.Matches(n) {
+				continue
+			}
+			matches := c.matching(r.Then)
+			switch {
+			case len(matches) == 0:
+				return fmt.Sprintf("%s needs %s, which cannot be picked.", describe(n), r.Then)
+			case len(matches) > 1:
+				// More than one trait would do. Not enough to go on.
+				continue
+			}
+			m := matches[0]
+			if !placeable[m.Kind] {
+				return fmt.Sprintf("%s needs %s, which no region accepts.", describe(n), describe(m))
+			}
+			if _, ok := because[m]; ok || m == a {
+				continue
+			}
+			because[m] = r.String()
+			needs = append(needs, m)
+		}
+	}
+	// Check whether any two needs exclude each other.
+	for i, x := range needs {
+		for _, y := range needs[i:] {
+			if !c.excludes(x, y) {
+				continue
+			}
+			// Explain how we got here.
+			chain := make([]string, 0, 2)
+			for _, n := range []*Asset{x, y} {
+				if r, ok := because[n]; ok {
+					chain = append(chain, r)
+				}
+			}
+			var via string
+			if len(chain) > 0 {
+				via = fmt.Sprintf(" (%s)", strings.Join(chain, "; "))
+			}
+			if x == y {
+				return fmt.Sprintf("%s excludes itself%s.", describe(x), via)
+			}
+			return fmt.Sprintf("%s and %s are both needed, but exclude each other%s.", describe(x), describe(y), via)
+		}
+	}
+	return ""
+}
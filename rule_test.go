@@ -0,0 +1,110 @@
+package artwork
+
+import (
+	"image"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testAsset returns the asset of testConfig, c, with the given kind and name.
+func testAsset(c *Configuration, kind, name string) *Asset {
+	for _, a := range c.Assets {
+		if a.Kind == kind && a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func TestSatisfied(t *testing.T) {
+	c := testConfig()
+	red, blue := testAsset(c, "background", "Red"), testAsset(c, "background", "Blue")
+	cap, crown := testAsset(c, "hat", "Cap"), testAsset(c, "hat", "Crown")
+	for _, test := range []struct {
+		rule   Rule
+		picked []*Asset
+		ok     bool
+	}{
+		{Rule{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}}, []*Asset{red, crown}, false},
+		{Rule{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}}, []*Asset{red, cap}, true},
+		// Exclusion works both ways.
+		{Rule{Excludes, TraitRef{"hat", "Crown"}, TraitRef{"background", "Red"}}, []*Asset{red, crown}, false},
+		// A reference without a name refers to every trait of its kind.
+		{Rule{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", ""}}, []*Asset{red, cap}, false},
+		{Rule{Requires, TraitRef{"hat", "Crown"}, TraitRef{"background", "Red"}}, []*Asset{blue, crown}, false},
+		{Rule{Requires, TraitRef{"hat", "Crown"}, TraitRef{"background", "Red"}}, []*Asset{red, crown}, true},
+		{Rule{Requires, TraitRef{"hat", "Crown"}, TraitRef{"background", "Red"}}, []*Asset{blue, cap}, true},
+		{Rule{Implies, TraitRef{"background", "Blue"}, TraitRef{"hat", "Crown"}}, []*Asset{blue, cap}, false},
+		{Rule{Implies, TraitRef{"background", "Blue"}, TraitRef{"hat", "Crown"}}, []*Asset{blue, crown}, true},
+	} {
+		c.Rules = []*Rule{&test.rule}
+		if err := c.Satisfied(test.picked); (err == nil) != test.ok {
+			t.Errorf("%s, with %d traits picked: got %v, expected it satisfied: %v.", &test.rule, len(test.picked), err, test.ok)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	for _, test := range []struct {
+		rules []*Rule
+		want  string
+	}{
+		{[]*Rule{{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}}}, ""},
+		{[]*Rule{{Requires, TraitRef{"hat", "Crown"}, TraitRef{"hat", "Top Hat"}}}, "which matches no weighted asset"},
+		{[]*Rule{{Requires, TraitRef{"", "Crown"}, TraitRef{"hat", "Cap"}}}, "refers to a trait with no kind"},
+		{[]*Rule{{Requires, TraitRef{"hat", "Cap"}, TraitRef{"cape", "Red"}}}, "hat/Cap needs cape/Red, which no region accepts."},
+		{[]*Rule{{Excludes, TraitRef{"hat", "Cap"}, TraitRef{"hat", "Cap"}}}, "hat/Cap excludes itself."},
+		// The crown needs the ruby, and the ruby needs red, but red excludes the crown.
+		{[]*Rule{
+			{Requires, TraitRef{"hat", "Crown"}, TraitRef{"jewel", "Ruby"}},
+			{Implies, TraitRef{"jewel", "Ruby"}, TraitRef{"background", "Red"}},
+			{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}},
+		}, "hat/Crown and background/Red are both needed, but exclude each other"},
+		// Either background would do, so nothing can be said.
+		{[]*Rule{
+			{Requires, TraitRef{"hat", "Crown"}, TraitRef{"background", ""}},
+			{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}},
+		}, ""},
+	} {
+		c := testConfig()
+		c.Assets = append(c.Assets, &Asset{Kind: "cape", Name: "Red", Weight: 1, Image: image.NewNRGBA(image.Rect(0, 0, 1, 1))})
+		c.Rules = test.rules
+		errs := c.checkRules()
+		if test.want == "" {
+			if len(errs) > 0 {
+				t.Errorf("Expected rules %v to pass, got:\n%s", test.rules, errs)
+			}
+			continue
+		}
+		if !strings.Contains(errs.Error(), test.want) {
+			t.Errorf("Expected rules %v to fail with %q, got:\n%s", test.rules, test.want, errs)
+		}
+	}
+}
+
+func TestBuildRules(t *testing.T) {
+	c := testConfig()
+	c.Rules = []*Rule{
+		{Excludes, TraitRef{"background", "Red"}, TraitRef{"hat", "Crown"}},
+		{Implies, TraitRef{"background", "Blue"}, TraitRef{"hat", "Crown"}},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); seed < 50; seed++ {
+		p := NewPiece(1, nil, nil)
+		if err := p.Build(c, rand.New(rand.NewSource(seed))); err != nil {
+			t.Fatal(err)
+		}
+		names := traitNames(p)
+		if names[0] == "background/Red" && names[1] != "hat/Cap" || names[0] == "background/Blue" && names[1] != "hat/Crown" {
+			t.Errorf("Built %v, against the rules.", names)
+		}
+	}
+	// The rules leave only 2 combinations.
+	g := NewCollectionGenerator(c, WithSupply(3), WithOutDir(t.TempDir()))
+	if _, err := g.Generate(); err == nil {
+		t.Errorf("Expected 3 pieces to be too many for the 2 combinations the rules allow.")
+	}
+}
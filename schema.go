@@ -0,0 +1,891 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/math/f64"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+LoadFile loads a composition file, path, and returns a validated *Configuration. Composition files may be written in YAML or JSON, and describe the entire composition tree. For example:
+
+	layers: layers        # Optional. A layer directory to load with LoadDir.
+	size: [1000, 1000]    # Optional. Canvas size, if root has no image.
+	interpolation: catmull-rom  # Optional. nearest-neighbor, bilinear, catmull-rom or approx-bilinear, the default.
+	pixel_art: false      # Optional. Scales pixels whole, by whole factors, in every region.
+	root:                 # The base asset. Its regions are the top of the tree.
+	  path: base.png
+	  regions:
+	    - coords: [500, 500]
+	      kinds: [background]
+	    - coords: [500, 300]
+	      kinds: [hat]
+	      scale: [0.5, 0.5]
+	    - coords: [700, 500]
+	      kinds: [badge]
+	      scale: [-1, 1]      # Optional. Negative scales mirror.
+	      rotate: 15          # Optional. Degrees, clockwise.
+	      skew: [10, 0]       # Optional. Degrees, along x and y.
+	      matrix: [1, 0, 0, 1]  # Optional. Applied last; [a, b, c, d, e, f] also accepted, ignoring c and f.
+	      blend: multiply     # Optional. normal, multiply, screen, overlay, soft-light, add, darken, lighten or color-dodge.
+	      opacity: 0.8        # Optional. Fully opaque, if omitted.
+	      anchor: bottom-center  # Optional. A named anchor, or fractions, [x, y]. Overrides the asset's pivot.
+	      interpolation: bilinear  # Optional. Overrides the configured interpolation.
+	      pixel_art: false    # Optional. Pixel art mode, for this region alone.
+	      clip: {mask: masks/badge.png, invert: false}  # Optional. Or "parent", to clip to the parent's image.
+	      z: 1                # Optional. Draw order, relative to the parent's. Negative draws beneath it.
+	      effects:            # Optional. Applied in order, from the layer's alpha.
+	        - {type: stroke, width: 2, color: "#000000"}
+	        - {type: drop-shadow, offset: [4, 4], blur: 4, color: "#00000080"}
+	        - {type: outer-glow, spread: 0, blur: 8, color: "#ffffffc0"}
+	assets:
+	  - kind: hat
+	    name: Red Hat
+	    path: hats/red.png
+	    weight: 0.25
+	    display_type: string  # Optional.
+	    hidden: false         # Optional. Hidden traits are left out of metadata.
+	    pivot: [32, 60]       # Optional. The pixel placed on a region's coords. Centered, if omitted.
+	    parts:              # Optional. More images of the same trait, placed like regions, but not picked.
+	      - path: hats/red-brim.png
+	        coords: [40, 50]
+	        z: -1             # Beneath the hat's parent.
+	    regions:
+	      - coords: [40, 10]
+	        kinds: [feather]
+	      - coords: [40, 90]
+	        kinds: [label]
+	    palette: ["#cc0000", "#880000"]  # Optional. Colors its variants recolor.
+	    variants:           # Optional. Recolored copies, each a trait of its own.
+	      - name: Blue Hat
+	        weight: 0.25
+	        colors: ["#0000cc", "#000088"]  # Replaces the palette, color for color.
+	      - name: Green Hat
+	        weight: 0.25
+	        hue: 120          # Shifts the palette's hue, or every color's, without one.
+	  - kind: label
+	    name: Number
+	    text:               # Rendered for each piece, in place of an image path.
+	      template: "#{{.Id}} {{index .Traits \"hat\"}}"  # A text/template, of the piece's Id, DNA, Attributes and Traits.
+	      font: fonts/display.otf  # Optional. Go Regular, if omitted.
+	      size: 24            # Optional. In pixels.
+	      color: "#ffffff"    # Optional. Black, if omitted.
+	      align: center       # Optional. left, center or right.
+	      max_width: 200      # Optional. Wraps between words to fit.
+	      line_spacing: 1.2   # Optional. In lines.
+	rules:
+	  - if: {kind: hat, name: Crown}
+	    requires: {kind: robe, name: Royal}
+	  - if: {kind: eyes, name: Laser}
+	    excludes: {kind: eyewear}   # Every trait of a kind, when name is omitted.
+	  - if: {kind: hat, name: Crown}
+	    implies: {kind: background, name: Gold}
+	conditions:           # Weights which depend on traits picked earlier.
+	  - if: {kind: background, name: Gold}
+	    then: {kind: hat, name: Crown}
+	    factor: 3           # Crowns are three times likelier on gold.
+	one_of_ones:          # Hand-crafted pieces, placed among those generated.
+	  - name: The Founder
+	    description: Drawn by hand.
+	    path: legendary/founder.png
+	    position: 1         # Optional. Placed at random, if omitted.
+	    attributes:
+	      - {trait_type: Legendary, value: Founder}
+	    data: {artist: Jane}  # Optional. Added to the piece's metadata data.
+
+Relative paths are resolved against the directory containing path. Every problem found while decoding, validating, or loading images is reported together, each prefixed with the file and line on which it was found.
+*/
+func LoadFile(path string) (*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err := fmt.Errorf("Failed to load composition file: %s", err)
+		logErr.Println(err)
+		return nil, err
+	}
+	c, err := ParseConfiguration(data, path)
+	if err != nil {
+		logErr.Println(err)
+		return c, err
+	}
+	return c, nil
+}
+
+// ParseConfiguration decodes composition data, as described by LoadFile, from data. The file name, file, is used for resolving relative paths and for reporting error positions. Returns the *Configuration, and an error if anything failed to decode, validate or load.
+func ParseConfiguration(data []byte, file string) (*Configuration, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	d := &decoder{
+		file: file,
+		dir:  filepath.Dir(file),
+		c:    NewConfiguration(),
+	}
+	if len(doc.Content) > 0 {
+		d.config(doc.Content[0])
+	}
+	if err := d.errs.Err(); err != nil {
+		return d.c, err
+	}
+	// Validate before loading, so we don't attempt to load images from bad paths.
+	if err := d.c.Validate(); err != nil {
+		return d.c, err
+	}
+	// Load images, once for each path, as variants share their asset's.
+	var errs Errors
+	loaded := make(map[string]image.Image)
+	load := func(a *Asset, source any) {
+		if a.IsLoaded() || a.Path == "" {
+			return
+		}
+		if img, ok := loaded[a.Path]; ok {
+			a.Image = img
+			return
+		}
+		if err := a.Load(); err != nil {
+			errs = append(errs, d.c.errorf(source, "%s", err))
+			return
+		}
+		loaded[a.Path] = a.Image
+	}
+	d.c.Walk(func(a *Asset) {
+		load(a, a)
+		for _, part := range a.Parts {
+			load(part.Asset, part)
+		}
+	})
+	// Load clip masks, of parts as well as regions.
+	d.c.Walk(func(a *Asset) {
+		regions := make([]*Region, 0, len(a.Regions)+len(a.Parts))
+		regions = append(regions, a.Regions...)
+		for _, r := range append(regions, a.Parts...) {
+			if r.Clip == nil || r.Clip.Mask != nil || r.Clip.Path == "" {
+				continue
+			}
+			if img, ok := loaded[r.Clip.Path]; ok {
+				r.Clip.Mask = img
+				continue
+			}
+			if err := r.Clip.Load(); err != nil {
+				errs = append(errs, d.c.errorf(r, "%s", err))
+				continue
+			}
+			loaded[r.Clip.Path] = r.Clip.Mask
+		}
+	})
+	return d.c, errs.Err()
+}
+
+// Position identifies a position in a composition file.
+type Position struct {
+	File         string
+	Line, Column int
+}
+
+// String returns the position in the conventional "file:line:column" form.
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// decoder decodes a composition file's node tree into a *Configuration, collecting errors as it goes.
+type decoder struct {
+	file string
+	dir  string
+	c    *Configuration
+	errs Errors
+}
+
+// position returns the Position of a node.
+func (d *decoder) position(n *yaml.Node) Position {
+	return Position{File: d.file, Line: n.Line, Column: n.Column}
+}
+
+// errorf records an error at the position of node, n.
+func (d *decoder) errorf(n *yaml.Node, format string, args ...any) {
+	d.errs = append(d.errs, fmt.Errorf("%s: %s", d.position(n), fmt.Sprintf(format, args...)))
+}
+
+// fields calls f with each key and value of mapping node, n, reporting any keys which are not in the allowed set.
+func (d *decoder) fields(n *yaml.Node, f func(key string, v *yaml.Node), allowed ...string) {
+	if n.Kind != yaml.MappingNode {
+		d.errorf(n, "Expected a mapping.")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		known := false
+		for _, a := range allowed {
+			if k.Value == a {
+				known = true
+				break
+			}
+		}
+		if !known {
+			d.errorf(k, "Unknown field %q.", k.Value)
+			continue
+		}
+		f(k.Value, v)
+	}
+}
+
+// decode decodes a node into v, recording an error if it fails.
+func (d *decoder) decode(n *yaml.Node, v any) bool {
+	if err := n.Decode(v); err != nil {
+		d.errorf(n, "%s", err)
+		return false
+	}
+	return true
+}
+
+// path resolves a path relative to the composition file's directory.
+func (d *decoder) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(d.dir, p)
+}
+
+// config decodes the document's top-level mapping.
+func (d *decoder) config(n *yaml.Node) {
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "layers":
+			var dir string
+			if !d.decode(v, &dir) {
+				return
+			}
+			lc, err := LoadDir(d.path(dir))
+			if err != nil {
+				d.errorf(v, "%s", err)
+			}
+			if lc != nil {
+				for _, a := range lc.Assets {
+					d.c.sources[a] = d.position(v)
+				}
+				d.c.Assets = append(d.c.Assets, lc.Assets...)
+			}
+		case "size":
+			var size []int
+			if !d.decode(v, &size) {
+				return
+			}
+			if len(size) != 2 || size[0] <= 0 || size[1] <= 0 {
+				d.errorf(v, "Size must be a pair of positive integers, [width, height].")
+				return
+			}
+			d.c.Bounds = image.Rect(0, 0, size[0], size[1])
+		case "interpolation":
+			d.interpolation(v, &d.c.Interpolation)
+		case "pixel_art":
+			d.decode(v, &d.c.PixelArt)
+		case "root":
+			var variants []*Asset
+			d.c.Root, variants = d.asset(v)
+			if len(variants) > 0 {
+				d.errorf(v, "The root asset can not have variants.")
+			}
+		case "assets":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of assets.")
+				return
+			}
+			for _, an := range v.Content {
+				a, variants := d.asset(an)
+				d.c.Assets = append(d.c.Assets, a)
+				d.c.Assets = append(d.c.Assets, variants...)
+			}
+		case "rules":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of rules.")
+				return
+			}
+			for _, rn := range v.Content {
+				if r := d.rule(rn); r != nil {
+					d.c.Rules = append(d.c.Rules, r)
+				}
+			}
+		case "conditions":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of conditions.")
+				return
+			}
+			for _, cn := range v.Content {
+				d.c.Conditions = append(d.c.Conditions, d.condition(cn))
+			}
+		case "one_of_ones":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of one of ones.")
+				return
+			}
+			for _, on := range v.Content {
+				d.c.OneOfOnes = append(d.c.OneOfOnes, d.oneOfOne(on))
+			}
+		}
+	}, "layers", "size", "interpolation", "pixel_art", "root", "assets", "rules", "conditions", "one_of_ones")
+}
+
+// rule decodes a rule mapping, which has an "if" trait, and one of an "excludes", "requires" or "implies" trait.
+func (d *decoder) rule(n *yaml.Node) *Rule {
+	r := new(Rule)
+	var types int
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "if":
+			r.If = d.trait(v)
+			return
+		case "excludes":
+			r.Type = Excludes
+		case "requires":
+			r.Type = Requires
+		case "implies":
+			r.Type = Implies
+		}
+		types++
+		r.Then = d.trait(v)
+	}, "if", "excludes", "requires", "implies")
+	if types != 1 {
+		d.errorf(n, "A rule must have exactly one of \"excludes\", \"requires\" or \"implies\".")
+		return nil
+	}
+	d.c.sources[r] = d.position(n)
+	return r
+}
+
+// condition decodes a condition mapping, which has "if" and "then" traits, and a factor by which to weigh the "then" trait.
+func (d *decoder) condition(n *yaml.Node) *Condition {
+	cd := &Condition{Factor: 1}
+	d.c.sources[cd] = d.position(n)
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "if":
+			cd.If = d.trait(v)
+		case "then":
+			cd.Then = d.trait(v)
+		case "factor":
+			d.decode(v, &cd.Factor)
+		}
+	}, "if", "then", "factor")
+	return cd
+}
+
+// oneOfOne decodes a one of one mapping.
+func (d *decoder) oneOfOne(n *yaml.Node) *OneOfOne {
+	o := new(OneOfOne)
+	d.c.sources[o] = d.position(n)
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "name":
+			d.decode(v, &o.Name)
+		case "description":
+			d.decode(v, &o.Description)
+		case "path":
+			if d.decode(v, &o.Path) {
+				o.Path = d.path(o.Path)
+			}
+		case "position":
+			d.decode(v, &o.Position)
+		case "attributes":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of attributes.")
+				return
+			}
+			for _, an := range v.Content {
+				o.Attributes = append(o.Attributes, d.attribute(an))
+			}
+		case "data":
+			d.decode(v, &o.Data)
+		}
+	}, "name", "description", "path", "position", "attributes", "data")
+	return o
+}
+
+// attribute decodes an attribute mapping, as found in CHIP-0007 metadata.
+func (d *decoder) attribute(n *yaml.Node) Attribute {
+	var a Attribute
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "trait_type":
+			d.decode(v, &a.TraitType)
+		case "value":
+			d.decode(v, &a.Value)
+		case "display_type":
+			d.decode(v, &a.DisplayType)
+		}
+	}, "trait_type", "value", "display_type")
+	return a
+}
+
+// trait decodes a trait reference mapping.
+func (d *decoder) trait(n *yaml.Node) TraitRef {
+	var t TraitRef
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "kind":
+			d.decode(v, &t.Kind)
+		case "name":
+			d.decode(v, &t.Name)
+		}
+	}, "kind", "name")
+	return t
+}
+
+// asset decodes an asset mapping. Returns the *Asset, and its palette variants, if any.
+func (d *decoder) asset(n *yaml.Node) (*Asset, []*Asset) {
+	a := NewAsset()
+	a.Weight = DefaultWeight
+	d.c.sources[a] = d.position(n)
+	var palette []color.NRGBA
+	var variantNodes []*yaml.Node
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "kind":
+			d.decode(v, &a.Kind)
+		case "name":
+			d.decode(v, &a.Name)
+		case "path":
+			if d.decode(v, &a.Path) {
+				a.Path = d.path(a.Path)
+			}
+		case "weight":
+			d.decode(v, &a.Weight)
+		case "display_type":
+			d.decode(v, &a.DisplayType)
+		case "hidden":
+			d.decode(v, &a.Hidden)
+		case "regions":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of regions.")
+				return
+			}
+			for _, rn := range v.Content {
+				a.Regions = append(a.Regions, d.region(rn))
+			}
+		case "parts":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of parts.")
+				return
+			}
+			for _, pn := range v.Content {
+				a.Parts = append(a.Parts, d.part(pn))
+			}
+		case "pivot":
+			var pivot []int
+			if !d.decode(v, &pivot) {
+				return
+			}
+			if len(pivot) != 2 {
+				d.errorf(v, "Pivot must be a pair of integers, [x, y].")
+				return
+			}
+			a.Pivot = &image.Point{pivot[0], pivot[1]}
+		case "text":
+			a.Text = d.text(v)
+		case "palette":
+			palette = d.colors(v)
+		case "variants":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of variants.")
+				return
+			}
+			variantNodes = v.Content
+		}
+	}, "kind", "name", "path", "weight", "display_type", "hidden", "regions", "parts", "pivot", "text", "palette", "variants")
+	// Variants are decoded last, as they recolor the asset's palette.
+	variants := make([]*Asset, 0, len(variantNodes))
+	for _, vn := range variantNodes {
+		variants = append(variants, d.variant(vn, a, palette))
+	}
+	return a, variants
+}
+
+// variant decodes a variant mapping, of an asset, a, with a source palette. A variant with "colors" replaces the palette's colors exactly, and one with "hue" shifts their hue, or that of every color, if there is no palette.
+func (d *decoder) variant(n *yaml.Node, a *Asset, palette []color.NRGBA) *Asset {
+	name, weight := "", DefaultWeight
+	p := &Palette{Source: palette}
+	var modes int
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "name":
+			d.decode(v, &name)
+		case "weight":
+			d.decode(v, &weight)
+		case "colors":
+			p.Mode, p.Target = RecolorExact, d.colors(v)
+			modes++
+		case "hue":
+			p.Mode = RecolorHueShift
+			d.decode(v, &p.Hue)
+			modes++
+		}
+	}, "name", "weight", "colors", "hue")
+	if modes != 1 {
+		d.errorf(n, "A variant must have one of \"colors\" or \"hue\".")
+	}
+	v := a.Variant(name, weight, p)
+	d.c.sources[v] = d.position(n)
+	for i, r := range v.Regions {
+		d.c.sources[r] = d.c.sources[a.Regions[i]]
+	}
+	for i, part := range v.Parts {
+		d.c.sources[part] = d.c.sources[a.Parts[i]]
+	}
+	return v
+}
+
+// colors decodes a sequence of hex colors.
+func (d *decoder) colors(n *yaml.Node) []color.NRGBA {
+	var hexes []string
+	if !d.decode(n, &hexes) {
+		return nil
+	}
+	colors := make([]color.NRGBA, 0, len(hexes))
+	for _, h := range hexes {
+		c, err := ParseColor(h)
+		if err != nil {
+			d.errorf(n, "%s", err)
+			continue
+		}
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+// part decodes a part mapping: a region mapping, without kinds, and with the "path" of the part's image, or its "text".
+func (d *decoder) part(n *yaml.Node) *Region {
+	if n.Kind != yaml.MappingNode {
+		d.errorf(n, "Expected a mapping.")
+		return NewRegion()
+	}
+	// Decode the path and text here, and the rest as a region.
+	asset := NewAsset()
+	rn := *n
+	rn.Content = make([]*yaml.Node, 0, len(n.Content))
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		switch k.Value {
+		case "path":
+			if d.decode(v, &asset.Path) {
+				asset.Path = d.path(asset.Path)
+			}
+			continue
+		case "text":
+			asset.Text = d.text(v)
+			continue
+		case "kinds":
+			d.errorf(k, "Parts are not picked, and have no kinds.")
+			continue
+		}
+		rn.Content = append(rn.Content, k, v)
+	}
+	part := d.region(&rn)
+	d.c.sources[part] = d.position(n)
+	asset.Parent = part
+	part.Asset = asset
+	return part
+}
+
+// region decodes a region mapping.
+func (d *decoder) region(n *yaml.Node) *Region {
+	r := NewRegion()
+	d.c.sources[r] = d.position(n)
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "coords":
+			var coords []int
+			if !d.decode(v, &coords) {
+				return
+			}
+			if len(coords) != 2 {
+				d.errorf(v, "Coords must be a pair of integers, [x, y].")
+				return
+			}
+			r.Coords = &image.Point{coords[0], coords[1]}
+		case "kinds":
+			// Allow a single kind, as well as a list.
+			if v.Kind == yaml.ScalarNode {
+				var kind string
+				if d.decode(v, &kind) {
+					r.Kinds = append(r.Kinds, kind)
+				}
+				return
+			}
+			d.decode(v, &r.Kinds)
+		case "scale":
+			// Allow a single, uniform scale factor, as well as a pair.
+			if v.Kind == yaml.ScalarNode {
+				var s float64
+				if d.decode(v, &s) {
+					r.Scale = &Scale{s, s}
+				}
+				return
+			}
+			var s []float64
+			if !d.decode(v, &s) {
+				return
+			}
+			if len(s) != 2 {
+				d.errorf(v, "Scale must be a number, or a pair of numbers, [x, y].")
+				return
+			}
+			r.Scale = &Scale{s[0], s[1]}
+		case "rotate":
+			var deg float64
+			if d.decode(v, &deg) {
+				d.transform(r).Rotate = deg
+			}
+		case "skew":
+			// Allow a single skew angle, along x, as well as a pair.
+			if v.Kind == yaml.ScalarNode {
+				var deg float64
+				if d.decode(v, &deg) {
+					d.transform(r).SkewX = deg
+				}
+				return
+			}
+			var k []float64
+			if !d.decode(v, &k) {
+				return
+			}
+			if len(k) != 2 {
+				d.errorf(v, "Skew must be a number, or a pair of numbers, [x, y].")
+				return
+			}
+			t := d.transform(r)
+			t.SkewX, t.SkewY = k[0], k[1]
+		case "matrix":
+			var m []float64
+			if !d.decode(v, &m) {
+				return
+			}
+			if len(m) != 4 && len(m) != 6 {
+				d.errorf(v, "Matrix must be four numbers, [a, b, d, e], or six, [a, b, c, d, e, f].")
+				return
+			}
+			if len(m) == 4 {
+				m = []float64{m[0], m[1], 0, m[2], m[3], 0}
+			}
+			d.transform(r).Matrix = &f64.Aff3{m[0], m[1], m[2], m[3], m[4], m[5]}
+		case "blend":
+			var name string
+			if !d.decode(v, &name) {
+				return
+			}
+			mode, err := ParseBlendMode(name)
+			if err != nil {
+				d.errorf(v, "%s", err)
+				return
+			}
+			r.Blend = mode
+		case "opacity":
+			var o float64
+			if d.decode(v, &o) {
+				r.Opacity = &o
+			}
+		case "anchor":
+			// Allow a named anchor, as well as a pair of fractions.
+			if v.Kind == yaml.ScalarNode {
+				var name string
+				if !d.decode(v, &name) {
+					return
+				}
+				anchor, err := ParseAnchor(name)
+				if err != nil {
+					d.errorf(v, "%s", err)
+					return
+				}
+				r.Anchor = &anchor
+				return
+			}
+			var xy []float64
+			if !d.decode(v, &xy) {
+				return
+			}
+			if len(xy) != 2 {
+				d.errorf(v, "Anchor must be a name, such as bottom-center, or a pair of fractions, [x, y].")
+				return
+			}
+			r.Anchor = &Anchor{xy[0], xy[1]}
+		case "interpolation":
+			d.interpolation(v, &r.Interpolation)
+		case "pixel_art":
+			d.decode(v, &r.PixelArt)
+		case "z":
+			d.decode(v, &r.Z)
+		case "clip":
+			r.Clip = d.clip(v)
+		case "effects":
+			if v.Kind != yaml.SequenceNode {
+				d.errorf(v, "Expected a sequence of effects.")
+				return
+			}
+			for _, en := range v.Content {
+				if e := d.effect(en); e != nil {
+					r.Effects = append(r.Effects, e)
+				}
+			}
+		}
+	}, "coords", "kinds", "scale", "rotate", "skew", "matrix", "blend", "opacity", "anchor", "interpolation", "pixel_art", "effects", "clip", "z")
+	return r
+}
+
+// effect decodes an effect mapping, whose "type" determines its other fields. Returns nil if the effect could not be decoded.
+func (d *decoder) effect(n *yaml.Node) Effect {
+	if n.Kind != yaml.MappingNode {
+		d.errorf(n, "Expected a mapping.")
+		return nil
+	}
+	var kind string
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "type" {
+			d.decode(n.Content[i+1], &kind)
+		}
+	}
+	switch kind {
+	case "drop-shadow":
+		e := &DropShadow{Offset: image.Point{4, 4}, Blur: 4, Color: color.NRGBA{0, 0, 0, 0x80}}
+		d.fields(n, func(key string, v *yaml.Node) {
+			switch key {
+			case "offset":
+				var offset []int
+				if !d.decode(v, &offset) {
+					return
+				}
+				if len(offset) != 2 {
+					d.errorf(v, "Offset must be a pair of integers, [x, y].")
+					return
+				}
+				e.Offset = image.Point{offset[0], offset[1]}
+			case "blur":
+				d.decode(v, &e.Blur)
+			case "color":
+				d.color(v, &e.Color)
+			}
+		}, "type", "offset", "blur", "color")
+		return e
+	case "stroke":
+		e := &Stroke{Width: 1, Color: color.NRGBA{0, 0, 0, 0xff}}
+		d.fields(n, func(key string, v *yaml.Node) {
+			switch key {
+			case "width":
+				d.decode(v, &e.Width)
+			case "color":
+				d.color(v, &e.Color)
+			}
+		}, "type", "width", "color")
+		return e
+	case "outer-glow":
+		e := &OuterGlow{Blur: 8, Color: color.NRGBA{0xff, 0xff, 0xff, 0xc0}}
+		d.fields(n, func(key string, v *yaml.Node) {
+			switch key {
+			case "spread":
+				d.decode(v, &e.Spread)
+			case "blur":
+				d.decode(v, &e.Blur)
+			case "color":
+				d.color(v, &e.Color)
+			}
+		}, "type", "spread", "blur", "color")
+		return e
+	}
+	d.errorf(n, "Unknown effect type %q. Expected one of: drop-shadow, outer-glow, stroke.", kind)
+	return nil
+}
+
+// clip decodes a clip: "parent", to clip to the parent asset's image, or a mapping, with an optional "mask" image path, and "invert".
+func (d *decoder) clip(n *yaml.Node) *Clip {
+	cl := new(Clip)
+	if n.Kind == yaml.ScalarNode {
+		var s string
+		if d.decode(n, &s) && s != "parent" {
+			d.errorf(n, "Clip must be \"parent\", or a mapping with a mask and invert.")
+		}
+		return cl
+	}
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "mask":
+			if d.decode(v, &cl.Path) {
+				cl.Path = d.path(cl.Path)
+			}
+		case "invert":
+			d.decode(v, &cl.Invert)
+		}
+	}, "mask", "invert")
+	return cl
+}
+
+// color decodes a hex color into c.
+func (d *decoder) color(n *yaml.Node, c *color.NRGBA) {
+	var h string
+	if !d.decode(n, &h) {
+		return
+	}
+	parsed, err := ParseColor(h)
+	if err != nil {
+		d.errorf(n, "%s", err)
+		return
+	}
+	*c = parsed
+}
+
+// text decodes a text mapping. The font path is resolved like any other.
+func (d *decoder) text(n *yaml.Node) *Text {
+	t := &Text{Color: color.NRGBA{0, 0, 0, 0xff}}
+	d.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "template":
+			d.decode(v, &t.Template)
+		case "font":
+			if d.decode(v, &t.Path) {
+				t.Path = d.path(t.Path)
+			}
+		case "size":
+			d.decode(v, &t.Size)
+		case "color":
+			d.color(v, &t.Color)
+		case "align":
+			var name string
+			if !d.decode(v, &name) {
+				return
+			}
+			align, err := ParseTextAlign(name)
+			if err != nil {
+				d.errorf(v, "%s", err)
+				return
+			}
+			t.Align = align
+		case "max_width":
+			d.decode(v, &t.MaxWidth)
+		case "line_spacing":
+			d.decode(v, &t.LineSpacing)
+		}
+	}, "template", "font", "size", "color", "align", "max_width", "line_spacing")
+	return t
+}
+
+// interpolation decodes the name of an interpolation into i.
+func (d *decoder) interpolation(n *yaml.Node, i *Interpolation) {
+	var name string
+	if !d.decode(n, &name) {
+		return
+	}
+	in, err := ParseInterpolation(name)
+	if err != nil {
+		d.errorf(n, "%s", err)
+		return
+	}
+	*i = in
+}
+
+// transform returns the region's transform, creating it if need be.
+func (d *decoder) transform(r *Region) *Transform {
+	if r.Transform == nil {
+		r.Transform = &Transform{}
+	}
+	return r.Transform
+}
@@ -0,0 +1,152 @@
+package artwork
+
+import (
+	"image"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	writeLayer(t, dir, "base.png", 100, 100)
+	writeLayer(t, dir, "red.png", 100, 100)
+	writeLayer(t, dir, "hats/cap.png", 20, 10)
+	writeLayer(t, dir, "plume.png", 4, 4)
+	want := &Configuration{
+		Bounds: image.Rect(0, 0, 100, 100),
+		Root: &Asset{Path: filepath.Join(dir, "base.png"), Weight: DefaultWeight, Regions: []*Region{
+			{Coords: &image.Point{50, 50}, Kinds: []string{"background"}},
+			{Coords: &image.Point{50, 30}, Kinds: []string{"hat"}, Scale: &Scale{0.5, 0.5}},
+		}},
+		Assets: []*Asset{
+			{Kind: "background", Name: "Red", Weight: DefaultWeight, Path: filepath.Join(dir, "red.png")},
+			{Kind: "hat", Name: "Cap", Weight: 0.25, Path: filepath.Join(dir, "hats/cap.png"), Regions: []*Region{
+				{Coords: &image.Point{4, 1}, Kinds: []string{"feather"}},
+			}},
+			{Kind: "feather", Name: "Plume", Weight: DefaultWeight, Path: filepath.Join(dir, "plume.png")},
+		},
+	}
+	for file, data := range map[string]string{
+		"art.yaml": `size: [100, 100]
+root:
+  path: base.png
+  regions:
+    - coords: [50, 50]
+      kinds: background
+    - coords: [50, 30]
+      kinds: [hat]
+      scale: 0.5
+assets:
+  - kind: background
+    name: Red
+    path: red.png
+  - kind: hat
+    name: Cap
+    path: hats/cap.png
+    weight: 0.25
+    regions:
+      - coords: [4, 1]
+        kinds: [feather]
+  - kind: feather
+    name: Plume
+    path: plume.png
+`,
+		"art.json": `{
+  "size": [100, 100],
+  "root": {
+    "path": "base.png",
+    "regions": [
+      {"coords": [50, 50], "kinds": "background"},
+      {"coords": [50, 30], "kinds": ["hat"], "scale": [0.5, 0.5]}
+    ]
+  },
+  "assets": [
+    {"kind": "background", "name": "Red", "path": "red.png"},
+    {"kind": "hat", "name": "Cap", "path": "hats/cap.png", "weight": 0.25, "regions": [
+      {"coords": [4, 1], "kinds": ["feather"]}
+    ]},
+    {"kind": "feather", "name": "Plume", "path": "plume.png"}
+  ]
+}
+`,
+	} {
+		c, err := ParseConfiguration([]byte(data), filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if c.Bounds != want.Bounds {
+			t.Errorf("%s: got bounds %v, expected %v.", file, c.Bounds, want.Bounds)
+		}
+		checkParsedAsset(t, file, c.Root, want.Root)
+		if len(c.Assets) != len(want.Assets) {
+			t.Fatalf("%s: got %d assets, expected %d.", file, len(c.Assets), len(want.Assets))
+		}
+		for i, a := range c.Assets {
+			checkParsedAsset(t, file, a, want.Assets[i])
+		}
+	}
+}
+
+// checkParsedAsset checks that an asset parsed from file, got, and its regions, have the fields of want, and that its image is loaded.
+func checkParsedAsset(t *testing.T, file string, got, want *Asset) {
+	t.Helper()
+	if got.Kind != want.Kind || got.Name != want.Name || got.Weight != want.Weight || got.Path != want.Path {
+		t.Errorf("%s: got asset %s/%s, weight %v, at %q, expected %s/%s, weight %v, at %q.", file, got.Kind, got.Name, got.Weight, got.Path, want.Kind, want.Name, want.Weight, want.Path)
+	}
+	if !got.IsLoaded() {
+		t.Errorf("%s: asset %q was not loaded.", file, got.Path)
+	}
+	if len(got.Regions) != len(want.Regions) {
+		t.Errorf("%s: asset %q has %d regions, expected %d.", file, got.Path, len(got.Regions), len(want.Regions))
+		return
+	}
+	for i, r := range got.Regions {
+		w := want.Regions[i]
+		if !reflect.DeepEqual(r.Coords, w.Coords) || !reflect.DeepEqual(r.Kinds, w.Kinds) || !reflect.DeepEqual(r.Scale, w.Scale) {
+			t.Errorf("%s: region %d of %q has coords %v, kinds %v and scale %v, expected %v, %v and %v.", file, i, got.Path, r.Coords, r.Kinds, r.Scale, w.Coords, w.Kinds, w.Scale)
+		}
+	}
+}
+
+func TestParseConfigurationErrors(t *testing.T) {
+	data := []byte(`size: [100, 100]
+root:
+  regions:
+    - coords: [50, 50]
+      kinds: [hat]
+      skew: [45, 45]
+assets:
+  - kind: shirt
+    name: Plain
+    colour: red
+`)
+	_, err := ParseConfiguration(data, "test.yaml")
+	if err == nil {
+		t.Fatal("Expected errors, got none.")
+	}
+	for _, want := range []string{
+		`test.yaml:10:5: Unknown field "colour".`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%s", want, err)
+		}
+	}
+
+	// Fix the unknown field, and check validation.
+	data = []byte(strings.Replace(string(data), "    colour: red\n", "", 1))
+	_, err = ParseConfiguration(data, "test.yaml")
+	if err == nil {
+		t.Fatal("Expected errors, got none.")
+	}
+	for _, want := range []string{
+		`test.yaml:4:7: Region kind "hat" matches no asset.`,
+		`test.yaml:4:7: Region transform is singular`,
+		`test.yaml:8:5: Asset "Plain" has no image path.`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%s", want, err)
+		}
+	}
+}
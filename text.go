@@ -0,0 +1,255 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultFontSize is the size, in pixels, at which text is rendered, unless told otherwise.
+const DefaultFontSize = 16.0
+
+// TextAlign is the alignment of the lines of a block of text.
+type TextAlign int
+
+const (
+	// AlignLeft aligns lines with the left of the block.
+	AlignLeft TextAlign = iota
+	// AlignCenter centers lines within the block.
+	AlignCenter
+	// AlignRight aligns lines with the right of the block.
+	AlignRight
+)
+
+// textAligns maps the name of each alignment to the alignment.
+var textAligns = map[string]TextAlign{
+	"left":   AlignLeft,
+	"center": AlignCenter,
+	"right":  AlignRight,
+}
+
+// String returns the name of the alignment.
+func (ta TextAlign) String() string {
+	for name, a := range textAligns {
+		if a == ta {
+			return name
+		}
+	}
+	return fmt.Sprintf("TextAlign(%d)", int(ta))
+}
+
+// ParseTextAlign returns the alignment with the given name, as returned by TextAlign.String. Returns an error if there is no such alignment.
+func ParseTextAlign(name string) (TextAlign, error) {
+	if a, ok := textAligns[strings.ToLower(name)]; ok {
+		return a, nil
+	}
+	names := make([]string, 0, len(textAligns))
+	for n := range textAligns {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("Unknown text alignment %q. Expected one of: %s.", name, strings.Join(names, ", "))
+}
+
+/*
+Text is text rendered in place of an asset's image, for each piece. Template is a text/template, executed with the piece's TextData, such as "#{{.Id}}", or "{{index .Traits \"Hat\"}}". The text is set in an OpenType font, loaded from Path, or Go Regular, if Path is empty, at Size pixels, in Color. Lines break at newlines, and, where MaxWidth is set, wrap between words to fit it, in pixels. Lines are aligned by Align, and spaced LineSpacing times the font's line height apart, or once, if zero.
+
+The rendered text is as wide as its widest line, or MaxWidth, if set, and as tall as its lines, so regions place and transform it like any other image.
+*/
+type Text struct {
+	Template    string
+	Path        string
+	Size        float64
+	Color       color.NRGBA
+	Align       TextAlign
+	MaxWidth    int
+	LineSpacing float64
+	mu          sync.Mutex
+	font        *opentype.Font
+	face        font.Face
+	tmpl        *template.Template
+}
+
+// TextData is the data with which a Text's Template is executed: the piece's Id, its DNA, its Attributes, and its Traits, the value of each of its attributes, by trait type.
+type TextData struct {
+	Id         uint
+	DNA        string
+	Attributes []Attribute
+	Traits     map[string]string
+}
+
+// newTextData returns the TextData of a piece.
+func newTextData(p *Piece) TextData {
+	data := TextData{Id: p.Id, DNA: p.DNA, Attributes: p.Attributes(), Traits: make(map[string]string)}
+	for _, a := range data.Attributes {
+		data.Traits[a.TraitType] = a.Value
+	}
+	return data
+}
+
+// Load parses the text's template, and loads its font. Returns an error if either could not be.
+func (t *Text) Load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.load()
+}
+
+// load loads the text as Load does, if it has not been already. The caller must hold t.mu.
+func (t *Text) load() error {
+	if t.tmpl == nil {
+		tmpl, err := template.New("text").Option("missingkey=zero").Parse(t.Template)
+		if err != nil {
+			return fmt.Errorf("Failed to parse text template: %s", err)
+		}
+		t.tmpl = tmpl
+	}
+	if t.font == nil {
+		src := goregular.TTF
+		if t.Path != "" {
+			var err error
+			if src, err = os.ReadFile(t.Path); err != nil {
+				return fmt.Errorf("Failed to load font: %s", err)
+			}
+		}
+		f, err := opentype.Parse(src)
+		if err != nil {
+			return fmt.Errorf("Failed to load font: Error while parsing %q: %s", t.Path, err)
+		}
+		t.font = f
+	}
+	if t.face == nil {
+		size := t.Size
+		if size <= 0 {
+			size = DefaultFontSize
+		}
+		// At 72 DPI, a point is a pixel.
+		face, err := opentype.NewFace(t.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return fmt.Errorf("Failed to load font: %s", err)
+		}
+		t.face = face
+	}
+	return nil
+}
+
+// Render renders the text for a piece, with data. Returns the rendered *image.NRGBA, or an error if the template or font failed.
+func (t *Text) Render(data TextData) (*image.NRGBA, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("Failed to render text: %s", err)
+	}
+	d := &font.Drawer{Face: t.face, Src: image.NewUniform(t.Color)}
+	lines := t.wrap(d, sb.String())
+	// Size the block to its widest line, or the wrapping width.
+	width := t.MaxWidth
+	widths := make([]int, len(lines))
+	for i, line := range lines {
+		widths[i] = d.MeasureString(line).Ceil()
+		if t.MaxWidth <= 0 && widths[i] > width {
+			width = widths[i]
+		}
+	}
+	metrics := t.face.Metrics()
+	spacing := t.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	lineHeight := int(math.Ceil(float64(metrics.Height.Ceil()) * spacing))
+	height := lineHeight*(len(lines)-1) + metrics.Ascent.Ceil() + metrics.Descent.Ceil()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	d.Dst = img
+	for i, line := range lines {
+		var x int
+		switch t.Align {
+		case AlignCenter:
+			x = (width - widths[i]) / 2
+		case AlignRight:
+			x = width - widths[i]
+		}
+		d.Dot = fixed.P(x, metrics.Ascent.Ceil()+i*lineHeight)
+		d.DrawString(line)
+	}
+	return img, nil
+}
+
+// wrap breaks s into lines at newlines, and, if the text has a MaxWidth, between words, so that each line fits it, as measured by d. Words too wide to fit are left whole, on lines of their own.
+func (t *Text) wrap(d *font.Drawer, s string) []string {
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(s, "\n") {
+		if t.MaxWidth <= 0 {
+			lines = append(lines, paragraph)
+			continue
+		}
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			next := word
+			if line != "" {
+				next = line + " " + word
+			}
+			if line != "" && d.MeasureString(next).Ceil() > t.MaxWidth {
+				lines = append(lines, line)
+				next = word
+			}
+			line = next
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// check checks the text: its template must parse, and its font load, at a finite size. Returns an error describing the first problem found, or nil.
+func (t *Text) check() error {
+	if !finite(t.Size, t.LineSpacing) || t.Size < 0 || t.LineSpacing < 0 {
+		return fmt.Errorf("Text size, %v, and line spacing, %v, must be finite and non-negative.", t.Size, t.LineSpacing)
+	}
+	if t.MaxWidth < 0 {
+		return fmt.Errorf("Text maximum width, %d, must not be negative.", t.MaxWidth)
+	}
+	if _, ok := textAligns[t.Align.String()]; !ok {
+		return fmt.Errorf("Text has an unknown alignment, %s.", t.Align)
+	}
+	return t.Load()
+}
+
+// renderText renders the text of every text asset in the piece's composition tree, below its root, for the piece, in place of its image.
+func (p *Piece) renderText() error {
+	data := newTextData(p)
+	var walk func(a *Asset) error
+	walk = func(a *Asset) error {
+		for _, regions := range [][]*Region{a.Parts, a.Regions} {
+			for _, region := range regions {
+				if region == nil || region.Asset == nil {
+					continue
+				}
+				if t := region.Asset.Text; t != nil {
+					img, err := t.Render(data)
+					if err != nil {
+						return err
+					}
+					region.Asset.Image = img
+				}
+				if err := walk(region.Asset); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(p.Asset)
+}
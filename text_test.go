@@ -0,0 +1,50 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTextRender(t *testing.T) {
+	data := TextData{Id: 42, Traits: map[string]string{"hat": "Red Hat"}}
+	white := color.NRGBA{255, 255, 255, 255}
+	text := &Text{Template: `#{{.Id}} {{index .Traits "hat"}}`, Size: 20, Color: white}
+	if err := text.check(); err != nil {
+		t.Fatalf("Checking text failed: %s", err)
+	}
+	line, err := text.Render(data)
+	if err != nil {
+		t.Fatalf("Rendering text failed: %s", err)
+	}
+	if line.Bounds().Dx() == 0 || line.Bounds().Dy() == 0 {
+		t.Fatalf("Rendered text is empty: %v.", line.Bounds())
+	}
+	var inked bool
+	for i := 3; i < len(line.Pix); i += 4 {
+		if line.Pix[i] == 0xff && line.Pix[i-3] == 0xff {
+			inked = true
+			break
+		}
+	}
+	if !inked {
+		t.Errorf("Rendered text has no opaque pixels in its color.")
+	}
+
+	// Wrapping to a narrower width adds lines, at the same width.
+	wrapped := &Text{Template: text.Template, Size: 20, Color: white, MaxWidth: line.Bounds().Dx() - 1, Align: AlignRight}
+	img, err := wrapped.Render(data)
+	if err != nil {
+		t.Fatalf("Rendering wrapped text failed: %s", err)
+	}
+	if want := image.Rect(0, 0, wrapped.MaxWidth, img.Bounds().Dy()); img.Bounds() != want || img.Bounds().Dy() <= line.Bounds().Dy() {
+		t.Errorf("Wrapped text has bounds %v, expected %v, taller than %v.", img.Bounds(), want, line.Bounds())
+	}
+
+	if err := (&Text{Template: "{{.Id"}).check(); err == nil {
+		t.Errorf("Expected a malformed template to fail its check.")
+	}
+	if _, err := ParseTextAlign("justify"); err == nil {
+		t.Errorf("Expected an unknown alignment to fail to parse.")
+	}
+}
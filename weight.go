@@ -0,0 +1,133 @@
+package artwork
+
+import (
+	"math"
+	"sort"
+)
+
+// weightTolerance is how far the sum of a WeightMap may stray from 1.0 before it is considered unnormalized.
+const weightTolerance = 1e-9
+
+// Weighable is the constraint for items which can be weighted: they must be comparable, and have a Key, which orders them stably.
+type Weighable interface {
+	comparable
+	Key() string
+}
+
+// WeightMap maps weighable items, such as attributes, assets or palettes, to their weights.
+type WeightMap[T Weighable] map[T]float64
+
+// Items returns the items of the weight map in a stable order, sorted by Key.
+func (m WeightMap[T]) Items() []T {
+	items := make([]T, 0, len(m))
+	for item := range m {
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Key() < items[j].Key()
+	})
+	return items
+}
+
+// Sum returns the sum of the weight map. Weights are summed in a stable order, so the result is always the same for the same weights.
+func (m WeightMap[T]) Sum() (sum float64) {
+	for _, item := range m.Items() {
+		sum += m[item]
+	}
+	return
+}
+
+// Normalize returns a copy of the weight map, scaled so that its weights sum to 1.0. Weights of zero or less are dropped. If the weights did not already sum to 1.0, a warning is logged.
+func (m WeightMap[T]) Normalize() WeightMap[T] {
+	n, sum := m.normalized()
+	if sum > 0 && !IsNormalized(sum) {
+		logErr.Printf("Warning: weights sum to %v, rather than 1.0. Normalizing.\n", sum)
+	}
+	return n
+}
+
+// normalized returns a normalized copy of the weight map, as Normalize does, but without warning. Returns the copy, and the sum of the original weights.
+func (m WeightMap[T]) normalized() (WeightMap[T], float64) {
+	// Sum in a stable order, so that the result is always the same.
+	var sum float64
+	for _, item := range m.Items() {
+		if w := m[item]; w > 0 {
+			sum += w
+		}
+	}
+	n := make(WeightMap[T], len(m))
+	if sum <= 0 {
+		return n, sum
+	}
+	for item, w := range m {
+		if w > 0 {
+			n[item] = w / sum
+		}
+	}
+	return n, sum
+}
+
+// IsNormalized reports whether a sum of weights is 1.0, give or take floating point error.
+func IsNormalized(sum float64) bool {
+	return math.Abs(sum-1.0) <= weightTolerance
+}
+
+/*
+Intervals computes an array of intervals which represent the normalized, weighted distribution of a WeightMap. Weights are normalized first, with a warning if they do not sum to 1.0.
+
+Here's a visual:
+
+	WeightMap{aV:0.2, aW:0.15, aX:0.25, aY:0.1, aZ:0.3}
+
+Would become something like,
+
+	WeightIntervals{aV:0.2, aW:0.35, aX:0.60, aY:0.7, aZ:1.0}
+
+Items are accumulated in the stable order given by Items, so the same map always produces the same intervals.
+
+This function returns the distribution as a *WeightInterval slice
+*/
+func (m WeightMap[T]) Intervals() WeightIntervals[T] {
+	n := m.Normalize()
+	// Make the CDF slice
+	prevSum := 0.0
+	ints := make(WeightIntervals[T], 0, len(n))
+	for _, item := range n.Items() {
+		prevSum += n[item]
+		ints = append(ints, &WeightInterval[T]{
+			Item:   item,
+			Weight: prevSum,
+		})
+	}
+	return ints
+}
+
+// WeightInterval is an item's upper bound in a weighted distribution.
+type WeightInterval[T Weighable] struct {
+	Item   T
+	Weight float64
+}
+
+// WeightIntervals is a weighted distribution, computed by WeightMap.Intervals.
+type WeightIntervals[T Weighable] []*WeightInterval[T]
+
+// Pick returns the first item for which f is less than its computed distribution threshold. It assumes itself to be sorted. Returns the zero value of T if there is nothing to pick.
+func (w WeightIntervals[T]) Pick(f float64) T {
+	for _, wi := range w {
+		// Since slice is sorted, this is all we should need.
+		if f < wi.Weight {
+			return wi.Item
+		}
+	}
+	// Guard against floating point error.
+	if len(w) > 0 {
+		return w[len(w)-1].Item
+	}
+	var zero T
+	return zero
+}
+
+// Sampler is implemented by types which choose an item from a weighted distribution, given f, a number in [0.0, 1.0). Both WeightIntervals and *AliasTable are Samplers.
+type Sampler[T Weighable] interface {
+	Pick(f float64) T
+}
@@ -0,0 +1,91 @@
+package artwork
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	m := AttributeWeightMap{
+		{TraitType: "Hat", Value: "Red"}:  2,
+		{TraitType: "Hat", Value: "Blue"}: 6,
+		{TraitType: "Hat", Value: "None"}: 0,
+	}
+	n := m.Normalize()
+	if !IsNormalized(n.Sum()) {
+		t.Errorf("Normalized weights sum to %v.", n.Sum())
+	}
+	if w := n[Attribute{TraitType: "Hat", Value: "Blue"}]; w != 0.75 {
+		t.Errorf("Expected Blue to have a weight of 0.75, got %v.", w)
+	}
+	if _, ok := n[Attribute{TraitType: "Hat", Value: "None"}]; ok {
+		t.Errorf("Expected unweighted None to be dropped.")
+	}
+}
+
+func TestIntervalsStable(t *testing.T) {
+	m := testWeights()
+	first := m.Intervals()
+	for i := 0; i < 10; i++ {
+		for j, wi := range m.Intervals() {
+			if *wi != *first[j] {
+				t.Fatalf("Intervals changed between calls: %+v != %+v", *wi, *first[j])
+			}
+		}
+	}
+}
+
+func TestVariantDNA(t *testing.T) {
+	dir := t.TempDir()
+	writeLayer(t, dir, "hat.png", 2, 2)
+	config := `size: [4, 4]
+root:
+  regions:
+    - coords: [2, 2]
+      kinds: [hat]
+assets:
+  - kind: hat
+    name: Cap
+    path: hat.png
+    variants:
+      - name: Green
+        hue: 120
+      - name: Blue
+        hue: 240
+`
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Picks, and so DNA, depend only on the configuration and seed, however often it is loaded.
+	dna := func() []string {
+		c, err := LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dnas := make([]string, 0, 20)
+		for seed := int64(0); seed < 20; seed++ {
+			p := NewPiece(1, nil, nil)
+			if err := p.Build(c, rand.New(rand.NewSource(seed))); err != nil {
+				t.Fatal(err)
+			}
+			dnas = append(dnas, p.DNA)
+		}
+		return dnas
+	}
+	first := dna()
+	for i := 0; i < 5; i++ {
+		if again := dna(); !reflect.DeepEqual(again, first) {
+			t.Fatalf("Loading the same configuration again gave DNA %v, then %v.", first, again)
+		}
+	}
+	// Variants left unnamed can not be told apart.
+	unnamed := strings.NewReplacer("name: Green\n        ", "", "name: Blue\n        ", "").Replace(config)
+	if _, err := ParseConfiguration([]byte(unnamed), path); err == nil || !strings.Contains(err.Error(), `Asset "" has the same kind, "hat", and name as another.`) {
+		t.Errorf("Expected unnamed variants to be rejected, got %v.", err)
+	}
+}
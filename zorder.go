@@ -0,0 +1,209 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// drawItem is an entry in a flattened draw list: an asset of the composition tree, and its Z, the sum of the Z of each region along its branch.
+type drawItem struct {
+	Asset *Asset
+	Z     int
+}
+
+// drawList flattens the asset's branch of the composition tree into a draw list, sorted by Z. Assets with the same Z keep their order in the tree, parents before children, and siblings in region order, after the parent's parts.
+func (a *Asset) drawList() []drawItem {
+	list := make([]drawItem, 0)
+	var walk func(a *Asset, z int)
+	walk = func(a *Asset, z int) {
+		list = append(list, drawItem{a, z})
+		for _, regions := range [][]*Region{a.Parts, a.Regions} {
+			for _, region := range regions {
+				if region != nil && region.Asset != nil {
+					walk(region.Asset, z+region.Z)
+				}
+			}
+		}
+	}
+	walk(a, 0)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Z < list[j].Z
+	})
+	return list
+}
+
+// layered reports whether the asset's draw list has more than one Z, and so must be composited as a draw list, rather than as a tree.
+func (a *Asset) layered() bool {
+	if a == nil {
+		return false
+	}
+	list := a.drawList()
+	return list[0].Z != list[len(list)-1].Z
+}
+
+// fragment is what a branch of a draw list contributes to the composite: the composite of the branch, less the sub-branches pulled out of it, drawn offset by Offset, with the transforms of every region along the way applied. It is blended by its own region's Blend mode, at Opacity, the product of the opacities along its branch.
+type fragment struct {
+	drawItem
+	Image   image.Image
+	Offset  image.Point
+	Blend   BlendMode
+	Opacity float64
+}
+
+// placed returns the bounds at which the fragment is drawn.
+func (f *fragment) placed() image.Rectangle {
+	return f.Image.Bounds().Add(f.Offset)
+}
+
+// compositeDrawList composites the asset's draw list, drawing each fragment in turn onto one canvas, where the full tree would place it, so that each blends with everything drawn beneath it.
+func (a *Asset) compositeDrawList() (image.Image, error) {
+	frags, bounds, _, err := a.fragments(0)
+	if err != nil || frags == nil {
+		return nil, err
+	}
+	// Fragments are gathered in tree order, so sorting them stably gives the draw list's order.
+	sort.SliceStable(frags, func(i, j int) bool {
+		return frags[i].Z < frags[j].Z
+	})
+	canvas := image.NewNRGBA(bounds)
+	for _, f := range frags {
+		Blend(canvas, f.placed(), f.Image, f.Image.Bounds().Min, f.Blend, f.Opacity)
+	}
+	return canvas, nil
+}
+
+/*
+fragments composites the asset's branch, where z is the asset's Z, as composite does, but for the sub-branches of regions with a Z of their own, which are pulled out as fragments of their own. Returns the fragments, the first of which is the asset's own, and the rest those pulled out, in tree order. Also returns the bounds of the branch, as composite would grow them, and the point to place at its region's coordinates, or nil if it should be centered.
+
+Each region's effects and clip apply to its branch less those pulled out, and the fragments pulled out take only its opacity.
+*/
+func (a *Asset) fragments(z int) ([]*fragment, image.Rectangle, *image.Point, error) {
+	if a == nil {
+		return nil, image.Rectangle{}, nil, nil
+	}
+	// A branch of one Z is composited as a tree.
+	if !a.layered() {
+		comp, anchor, err := a.composite()
+		if err != nil {
+			return nil, image.Rectangle{}, nil, err
+		}
+		return []*fragment{{drawItem: drawItem{a, z}, Image: comp, Opacity: 1}}, comp.Bounds(), anchor, nil
+	}
+	if !a.IsLoaded() {
+		err := fmt.Errorf("Error compositing asset %q: image not loaded.", a.Name)
+		logErr.Println(err)
+		return nil, image.Rectangle{}, nil, err
+	}
+	img := a.image()
+	abounds := img.Bounds().Canon()
+	var canvas draw.Image = image.NewNRGBA(abounds)
+	draw.Draw(canvas, abounds, img, abounds.Min, draw.Src)
+	frags := []*fragment{{drawItem: drawItem{a, z}, Opacity: 1}}
+	pulled := image.Rectangle{}
+	regions := make([]*Region, 0, len(a.Parts)+len(a.Regions))
+	regions = append(regions, a.Parts...)
+	for _, region := range append(regions, a.Regions...) {
+		if region == nil {
+			continue
+		}
+		branch, cbounds, anchor, err := region.fragments(z + region.Z)
+		if err != nil {
+			return nil, image.Rectangle{}, nil, err
+		}
+		if branch == nil {
+			continue
+		}
+		// Place the branch's own fragment as composite does, with the region's effects and clip, and blend it onto this asset's, unless the region has a Z of its own.
+		offset := region.place(cbounds.Canon(), anchor, abounds)
+		own := branch[0]
+		own.Image = region.finish(own.Image, own.Offset.Add(offset), img)
+		if region.Z == 0 {
+			dst := own.placed().Add(offset)
+			canvas = GrowImage(canvas, dst)
+			Blend(canvas, dst, own.Image, own.Image.Bounds().Min, region.Blend, region.opacity())
+			branch = branch[1:]
+		} else {
+			own.Blend = region.Blend
+		}
+		// Pull out the rest, placed with the branch, and faded by the region's opacity.
+		for _, f := range branch {
+			f.Offset = f.Offset.Add(offset)
+			f.Opacity *= region.opacity()
+			pulled = pulled.Union(f.placed())
+		}
+		frags = append(frags, branch...)
+	}
+	frags[0].Image = canvas
+	bounds := canvas.Bounds().Union(pulled)
+	if a.Parent == nil {
+		return frags, bounds, nil, nil
+	}
+	ax, ay, anchored := a.Parent.anchor(a, abounds)
+	if a.Parent.PixelArt {
+		ax, ay = math.Floor(ax), math.Floor(ay)
+	}
+	// Transform every fragment about the center of the whole branch, as composite transforms the branch.
+	if a.Parent.affine() {
+		m := a.Parent.Matrix()
+		m[2], m[5] = 0, 0
+		cx, cy := float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2
+		ax, ay = m[0]*(ax-cx)+m[1]*(ay-cy), m[3]*(ax-cx)+m[4]*(ay-cy)
+		for _, f := range frags {
+			f.Image, f.Offset = transformAbout(f.Image, f.Offset, m, cx, cy, a.Parent.interpolator()), image.Point{}
+		}
+		return frags, affineBounds(bounds, m, cx, cy), roundAnchor(ax, ay, anchored), nil
+	}
+	if a.Parent.Scale != nil {
+		sbounds := ScaleRectangle(a.Parent.Scale.X, a.Parent.Scale.Y, bounds)
+		if sbounds != bounds && !bounds.Empty() {
+			kx, ky := float64(sbounds.Dx())/float64(bounds.Dx()), float64(sbounds.Dy())/float64(bounds.Dy())
+			m := f64.Aff3{
+				kx, 0, float64(sbounds.Min.X) - float64(bounds.Min.X)*kx,
+				0, ky, float64(sbounds.Min.Y) - float64(bounds.Min.Y)*ky,
+			}
+			ax, ay = m[0]*ax+m[2], m[4]*ay+m[5]
+			for _, f := range frags {
+				f.Image, f.Offset = transformAbout(f.Image, f.Offset, m, 0, 0, a.Parent.interpolator()), image.Point{}
+			}
+			return frags, sbounds, roundAnchor(ax, ay, anchored), nil
+		}
+	}
+	return frags, bounds, roundAnchor(ax, ay, anchored), nil
+}
+
+// affineBounds returns bounds large enough to hold a rectangle, r, transformed by an affine transform, m, about the point cx, cy.
+func affineBounds(r image.Rectangle, m f64.Aff3, cx, cy float64) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		dx, dy := float64(p.X)-cx, float64(p.Y)-cy
+		x, y := m[0]*dx+m[1]*dy+m[2], m[3]*dx+m[4]*dy+m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	// Allow for rounding error, as TransformImage does.
+	const epsilon = 1e-9
+	return image.Rect(int(math.Floor(minX+epsilon)), int(math.Floor(minY+epsilon)), int(math.Ceil(maxX-epsilon)), int(math.Ceil(maxY-epsilon)))
+}
+
+// transformAbout resamples an image, src, drawn offset by offset, by an affine transform, m, about the point cx, cy, with an Interpolator, interp, or ApproxBiLinear, if nil. Returns the transformed *image.NRGBA, with bounds large enough to hold the whole of it, where it is to be drawn.
+func transformAbout(src image.Image, offset image.Point, m f64.Aff3, cx, cy float64, interp xdraw.Interpolator) *image.NRGBA {
+	sb := src.Bounds()
+	dst := image.NewNRGBA(affineBounds(sb.Add(offset), m, cx, cy))
+	ox, oy := float64(offset.X)-cx, float64(offset.Y)-cy
+	s2d := f64.Aff3{
+		m[0], m[1], m[0]*ox + m[1]*oy + m[2],
+		m[3], m[4], m[3]*ox + m[4]*oy + m[5],
+	}
+	if interp == nil {
+		interp = xdraw.ApproxBiLinear
+	}
+	interp.Transform(dst, s2d, src, sb, draw.Src, nil)
+	return dst
+}
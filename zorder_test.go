@@ -0,0 +1,171 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// square returns an image, size pixels square, filled with c.
+func square(size int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestZOrder(t *testing.T) {
+	red, green, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	// A head, with hair in a region of its own, and a hat in a region of the hair's.
+	build := func(hairZ, hatZ int) *Asset {
+		base := &Asset{Image: image.NewNRGBA(image.Rect(0, 0, 10, 10))}
+		head := &Region{Coords: &image.Point{5, 5}}
+		head.Asset = &Asset{Image: square(4, red), Parent: head}
+		hair := &Region{Coords: &image.Point{2, 2}, Z: hairZ}
+		hair.Asset = &Asset{Image: square(6, green), Parent: hair}
+		hat := &Region{Coords: &image.Point{3, 3}, Z: hatZ}
+		hat.Asset = &Asset{Image: square(2, blue), Parent: hat}
+		hair.Asset.Regions = []*Region{hat}
+		head.Asset.Regions = []*Region{hair}
+		base.Regions = []*Region{head}
+		return base
+	}
+	for _, test := range []struct {
+		hairZ, hatZ int
+		head        color.NRGBA // The color of the head, away from the hat.
+		hat         color.NRGBA // The color where the hat sits.
+	}{
+		{0, 0, green, blue},
+		// Hair behind the head, though its child.
+		{-1, 0, red, red},
+		// Hair behind the head, and its hat back above it.
+		{-1, 2, red, blue},
+	} {
+		comp, err := build(test.hairZ, test.hatZ).Composite()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := color.NRGBAModel.Convert(comp.At(6, 6)); got != test.head {
+			t.Errorf("With hair at z %d, and hat at %d, the head is %v, expected %v.", test.hairZ, test.hatZ, got, test.head)
+		}
+		if got := color.NRGBAModel.Convert(comp.At(4, 4)); got != test.hat {
+			t.Errorf("With hair at z %d, and hat at %d, the hat is %v, expected %v.", test.hairZ, test.hatZ, got, test.hat)
+		}
+	}
+
+	// A hat above the rest blends with everything beneath it, not only its own layer.
+	base := &Asset{Image: square(10, red)}
+	shade := &Region{Coords: &image.Point{5, 5}, Z: 1, Blend: BlendMultiply}
+	shade.Asset = &Asset{Image: square(4, green), Parent: shade}
+	base.Regions = []*Region{shade}
+	comp, err := base.Composite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := color.NRGBAModel.Convert(comp.At(5, 5)), (color.NRGBA{0, 0, 0, 255}); got != want {
+		t.Errorf("A layer multiplied over red gave %v, expected %v.", got, want)
+	}
+}
+
+func TestDrawList(t *testing.T) {
+	// Z which keeps the tree's order draws as the tree does.
+	for name, build := range map[string]func() *Asset{
+		// A branch scaled, rotated and anchored, with a child drawn above it, beyond its bounds, in pixel art mode, so that every pixel is exact.
+		"transformed": func() *Asset {
+			base := &Asset{Image: square(24, color.NRGBA{255, 255, 255, 255})}
+			arm := &Region{Coords: &image.Point{16, 16}, Scale: &Scale{2, 2}, PixelArt: true, Anchor: &Anchor{1, 1}}
+			arm.Asset = &Asset{Image: square(3, color.NRGBA{255, 0, 0, 255}), Parent: arm}
+			hand := &Region{Coords: &image.Point{3, 1}, Transform: &Transform{Rotate: 90}, PixelArt: true, Z: 1}
+			hand.Asset = &Asset{Image: image.NewNRGBA(image.Rect(0, 0, 2, 1)), Parent: hand}
+			hand.Asset.Image.(*image.NRGBA).SetNRGBA(0, 0, color.NRGBA{0, 0, 255, 255})
+			hand.Asset.Image.(*image.NRGBA).SetNRGBA(1, 0, color.NRGBA{0, 255, 0, 255})
+			arm.Asset.Regions = []*Region{hand}
+			base.Regions = []*Region{arm}
+			return base
+		},
+		// A translucent hat above a background, shadowed and stroked, with a jewel: the hat and jewel take one shadow, one stroke, and fade once, together.
+		"effects": func() *Asset {
+			half := 0.5
+			base := &Asset{Image: square(16, color.NRGBA{255, 255, 255, 255})}
+			background := &Region{Coords: &image.Point{8, 8}}
+			background.Asset = &Asset{Image: square(12, color.NRGBA{255, 0, 0, 255}), Parent: background}
+			hat := &Region{Coords: &image.Point{8, 6}, Z: 1, Opacity: &half, Effects: []Effect{
+				&DropShadow{Offset: image.Pt(2, 2), Color: color.NRGBA{0, 0, 0, 255}},
+				&Stroke{Width: 1, Color: color.NRGBA{255, 255, 0, 255}},
+			}}
+			hat.Asset = &Asset{Image: square(8, color.NRGBA{0, 0, 255, 255}), Parent: hat}
+			jewel := &Region{Coords: &image.Point{6, 6}}
+			jewel.Asset = &Asset{Image: square(2, color.NRGBA{0, 255, 0, 255}), Parent: jewel}
+			hat.Asset.Regions = []*Region{jewel}
+			base.Regions = []*Region{background, hat}
+			return base
+		},
+	} {
+		base := build()
+		if !base.layered() {
+			t.Fatalf("%s: expected a draw list of more than one Z.", name)
+		}
+		tree, _, err := base.composite()
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := base.compositeDrawList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.Bounds() != list.Bounds() {
+			t.Fatalf("%s: the tree composited to %v, but its draw list to %v.", name, tree.Bounds(), list.Bounds())
+		}
+		b := tree.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if got, want := list.At(x, y), tree.At(x, y); color.NRGBAModel.Convert(got) != color.NRGBAModel.Convert(want) {
+					t.Fatalf("%s: at %d, %d, the draw list gave %v, but the tree %v.", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParts(t *testing.T) {
+	red, green, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	// Hair, in front of the head, with a part behind it.
+	c := NewConfiguration()
+	c.Root = &Asset{Image: image.NewNRGBA(image.Rect(0, 0, 10, 10))}
+	c.Root.Regions = []*Region{{Coords: &image.Point{5, 5}, Kinds: []string{"head"}, Z: 1}}
+	head := &Asset{Kind: "head", Name: "Round", Weight: 1, Image: square(4, red)}
+	head.Regions = []*Region{{Coords: &image.Point{2, 0}, Kinds: []string{"hair"}}}
+	hair := &Asset{Kind: "hair", Name: "Long", Weight: 1, Image: square(2, blue)}
+	back := &Region{Coords: &image.Point{1, 3}, Z: -1}
+	back.Asset = &Asset{Image: square(6, green), Parent: back}
+	hair.Parts = []*Region{back}
+	c.Assets = []*Asset{head, hair}
+
+	p := NewPiece(1, nil, nil)
+	if err := p.Build(c, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Traits) != 2 || len(p.Attributes()) != 2 {
+		t.Fatalf("Expected a head, and hair, picked once, got traits %v.", p.Attributes())
+	}
+	comp, err := p.Asset.Composite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		p    image.Point
+		want color.NRGBA
+	}{
+		{image.Pt(5, 5), red},   // The head, over the back of the hair.
+		{image.Pt(2, 5), green}, // The back of the hair, beside the head.
+		{image.Pt(5, 2), blue},  // The front of the hair, over the head.
+	} {
+		if got := color.NRGBAModel.Convert(comp.At(test.p.X, test.p.Y)); got != test.want {
+			t.Errorf("At %v, got %v, expected %v.", test.p, got, test.want)
+		}
+	}
+}